@@ -1,11 +1,15 @@
 package api
 
 import (
+	"bytes"
+	"encoding/json"
+	stderrors "errors"
 	"lbc/fizzbuzz/domain"
-	"lbc/fizzbuzz/repository"
+	"lbc/fizzbuzz/internal"
 	"lbc/fizzbuzz/service"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/mwm-io/gapi/errors"
@@ -13,92 +17,413 @@ import (
 )
 
 type fizzBuzzController struct {
-	fizzBuzzService    service.FizzBuzzService
-	fizzBuzzRepository repository.FizzBuzzRepository
-	logger             *zap.Logger
+	fizzBuzzService service.FizzBuzzService
+	logger          *zap.Logger
+	limits          internal.LimitsConfig
+	server          internal.ServerConfig
 }
 
 type FizzBuzzResponse struct {
 	Result string `json:"result"`
+	// Next and Prev are the start of the adjacent windows, omitted when there is none
+	Next int `json:"next,omitempty"`
+	Prev int `json:"prev,omitempty"`
+}
+
+// FizzBuzzCountsResponse adds, for int1/int2 requests, the count of each of the four categories
+type FizzBuzzCountsResponse struct {
+	domain.FizzBuzzCounts
+	Str1 *int `json:"str1,omitempty"`
+	Str2 *int `json:"str2,omitempty"`
+	Both *int `json:"both,omitempty"`
+}
+
+// FizzBuzzBatchItemResponse is the outcome of a single item of a batch, either a result or an error
+type FizzBuzzBatchItemResponse struct {
+	Result string       `json:"result,omitempty"`
+	Next   int          `json:"next,omitempty"`
+	Prev   int          `json:"prev,omitempty"`
+	Error  errors.Error `json:"error,omitempty"`
 }
 
 func SetupFizzBuzzController(
 	logger *zap.Logger,
 	router gin.IRouter,
 	fizzBuzzService service.FizzBuzzService,
-	fizzBuzzRepository repository.FizzBuzzRepository) {
+	limits internal.LimitsConfig,
+	server internal.ServerConfig) {
 	c := fizzBuzzController{
-		logger:             logger,
-		fizzBuzzService:    fizzBuzzService,
-		fizzBuzzRepository: fizzBuzzRepository,
+		logger:          logger,
+		fizzBuzzService: fizzBuzzService,
+		limits:          limits,
+		server:          server,
 	}
 
 	root := router.Group("/api/v1/fizzbuzz")
 	GET(root, "/", c.generateFizzBuzzEndpoint)
-	GET(root, "/stats", c.getFizzBuzzStatsEndpoint)
+	POST(root, "/", c.generateFizzBuzzFromBodyEndpoint)
+	POST(root, "/batch", c.generateFizzBuzzBatchEndpoint)
+	GET(root, "/term", c.getFizzBuzzTermEndpoint)
+	GET(root, "/counts", c.getFizzBuzzCountsEndpoint)
 }
 
 // generateFizzBuzzEndpoint handles the FizzBuzz generation request
 func (c *fizzBuzzController) generateFizzBuzzEndpoint(ctx *gin.Context) {
-	fbInput, err := GetQueryParams(ctx)
+	fbInput, err := GetQueryParams(ctx, c.limits)
 	if err != nil {
 		c.logger.Error("Failed to parse query parameters", zap.Error(err))
 		ctx.JSON(err.StatusCode(), gin.H{"error": err})
 		return
 	}
 
-	result, err := c.fizzBuzzService.GenerateFizzBuzz(fbInput)
+	c.generateFizzBuzz(ctx, fbInput)
+}
+
+// generateFizzBuzzFromBodyEndpoint handles the FizzBuzz generation request with parameters given as a JSON body
+func (c *fizzBuzzController) generateFizzBuzzFromBodyEndpoint(ctx *gin.Context) {
+	fbInput, err := GetBodyParams(ctx, c.limits)
+	if err != nil {
+		c.logger.Error("Failed to parse request body", zap.Error(err))
+		ctx.JSON(err.StatusCode(), gin.H{"error": err})
+		return
+	}
+
+	c.generateFizzBuzz(ctx, fbInput)
+}
+
+// generateFizzBuzzBatchEndpoint handles the generation of several sequences at once
+func (c *fizzBuzzController) generateFizzBuzzBatchEndpoint(ctx *gin.Context) {
+	fbInputs, err := GetBatchBodyParams(ctx, c.limits)
+	if err != nil {
+		c.logger.Error("Failed to parse request body", zap.Error(err))
+		ctx.JSON(err.StatusCode(), gin.H{"error": err})
+		return
+	}
+
+	for i, fbInput := range fbInputs {
+		if err := checkTerms(fbInput, c.limits); err != nil {
+			err = errors.Wrap(err).WithMessage("batch[%d]: %s", i, err.Message())
+			c.logger.Error("Failed to parse request body", zap.Error(err))
+			ctx.JSON(err.StatusCode(), gin.H{"error": err})
+			return
+		}
+	}
+
+	results, err := c.fizzBuzzService.GenerateFizzBuzzBatch(ctx.Request.Context(), fbInputs)
+	if err != nil {
+		c.logger.Error("Failed to generate FizzBuzz batch", zap.Error(err))
+		ctx.JSON(err.StatusCode(), gin.H{"error": err})
+		return
+	}
+
+	response := make([]FizzBuzzBatchItemResponse, len(results))
+	for i, result := range results {
+		if result.Err != nil {
+			response[i].Error = result.Err
+			continue
+		}
+
+		response[i] = FizzBuzzBatchItemResponse{
+			Result: result.Result,
+			Next:   fbInputs[i].NextStart(),
+			Prev:   fbInputs[i].PrevStart(),
+		}
+	}
+
+	ctx.JSON(http.StatusOK, response)
+}
+
+// generateFizzBuzz generates the sequence of fbInput in the requested format
+func (c *fizzBuzzController) generateFizzBuzz(ctx *gin.Context, fbInput domain.FizzBuzzInput) {
+	if isStream(ctx) {
+		setWindowLinks(ctx, fbInput)
+		c.writeFizzBuzz(ctx, fbInput, ctx.Query("stream"), true)
+		return
+	}
+
+	if err := checkTerms(fbInput, c.limits); err != nil {
+		c.logger.Error("Failed to parse query parameters", zap.Error(err))
+		ctx.JSON(err.StatusCode(), gin.H{"error": err})
+		return
+	}
+
+	format, err := negotiateFormat(ctx)
+	if err != nil {
+		c.logger.Error("Failed to negotiate format", zap.Error(err))
+		ctx.JSON(err.StatusCode(), gin.H{"error": err})
+		return
+	}
+
+	setWindowLinks(ctx, fbInput)
+	if format != defaultFormat {
+		c.writeFizzBuzz(ctx, fbInput, format, false)
+		return
+	}
+
+	result, err := c.fizzBuzzService.GenerateFizzBuzz(ctx.Request.Context(), fbInput)
 	if err != nil {
 		c.logger.Error("Failed to generate FizzBuzz", zap.Error(err))
 		ctx.JSON(err.StatusCode(), gin.H{"error": err})
 		return
 	}
 
-	ctx.JSON(http.StatusOK, FizzBuzzResponse{Result: result})
+	ctx.JSON(http.StatusOK, FizzBuzzResponse{
+		Result: result,
+		Next:   fbInput.NextStart(),
+		Prev:   fbInput.PrevStart(),
+	})
+}
+
+// getFizzBuzzTermEndpoint handles the request for a single term of the sequence
+func (c *fizzBuzzController) getFizzBuzzTermEndpoint(ctx *gin.Context) {
+	fbInput, err := GetQueryParams(ctx, c.limits)
+	if err != nil {
+		c.logger.Error("Failed to parse query parameters", zap.Error(err))
+		ctx.JSON(err.StatusCode(), gin.H{"error": err})
+		return
+	}
+
+	n, err := parseIntParam(ctx, "n", false)
+	if err != nil {
+		c.logger.Error("Failed to parse query parameters", zap.Error(err))
+		ctx.JSON(err.StatusCode(), gin.H{"error": err})
+		return
+	}
+
+	term, err := c.fizzBuzzService.GetTerm(ctx.Request.Context(), fbInput, n)
+	if err != nil {
+		c.logger.Error("Failed to get FizzBuzz term", zap.Error(err))
+		ctx.JSON(err.StatusCode(), gin.H{"error": err})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, term)
 }
 
-// GetQueryParams retrieves and parses query parameters with validation and defaults
-func GetQueryParams(ctx *gin.Context) (domain.FizzBuzzInput, errors.Error) {
-	int1, err := strconv.Atoi(ctx.Query("int1"))
+// getFizzBuzzCountsEndpoint handles the request for the number of terms of each category
+func (c *fizzBuzzController) getFizzBuzzCountsEndpoint(ctx *gin.Context) {
+	fbInput, err := GetQueryParams(ctx, c.limits)
 	if err != nil {
-		return domain.FizzBuzzInput{}, errors.BadRequest("failed_to_parse_int1", "failed to parse int1")
+		c.logger.Error("Failed to parse query parameters", zap.Error(err))
+		ctx.JSON(err.StatusCode(), gin.H{"error": err})
+		return
 	}
 
-	int2, err := strconv.Atoi(ctx.Query("int2"))
+	counts, err := c.fizzBuzzService.CountFizzBuzz(ctx.Request.Context(), fbInput)
 	if err != nil {
-		return domain.FizzBuzzInput{}, errors.BadRequest("failed_to_parse_int2", "failed to parse int2")
+		c.logger.Error("Failed to count FizzBuzz", zap.Error(err))
+		ctx.JSON(err.StatusCode(), gin.H{"error": err})
+		return
+	}
+
+	response := FizzBuzzCountsResponse{FizzBuzzCounts: counts}
+	if len(fbInput.Rules) == 0 {
+		var str1, str2, both int
+		for _, combination := range counts.Combinations {
+			switch {
+			case len(combination.Rules) == 2:
+				both = combination.Count
+			case combination.Rules[0] == 0:
+				str1 = combination.Count
+			default:
+				str2 = combination.Count
+			}
+		}
+		response.Str1, response.Str2, response.Both = &str1, &str2, &both
+	}
+
+	ctx.JSON(http.StatusOK, response)
+}
+
+// checkTerms rejects a valid input whose window holds more than limits.MaxTerms terms, as a sequence that is not streamed is built in memory.
+// Invalid inputs are left to the service, so that they get their own error.
+func checkTerms(input domain.FizzBuzzInput, limits internal.LimitsConfig) errors.Error {
+	if input.Validate() != nil {
+		return nil
+	}
+
+	first, last := input.Window()
+	if last-first+1 > limits.MaxTerms {
+		return errors.BadRequest("too_many_terms", "at most %d terms are returned at once, use start and count or stream the sequence", limits.MaxTerms)
+	}
+
+	return nil
+}
+
+// setWindowLinks sets a Link header to the adjacent windows of a valid input given as query parameters,
+// so that formats without room for the next and prev cursors can still be paged
+func setWindowLinks(ctx *gin.Context, input domain.FizzBuzzInput) {
+	if ctx.Request.Method != http.MethodGet || input.Validate() != nil {
+		return
+	}
+
+	var links []string
+	for _, link := range []struct {
+		rel   string
+		start int
+	}{{"next", input.NextStart()}, {"prev", input.PrevStart()}} {
+		if link.start == 0 {
+			continue
+		}
+
+		u := *ctx.Request.URL
+		query := u.Query()
+		query.Set("start", strconv.Itoa(link.start))
+		u.RawQuery = query.Encode()
+		links = append(links, "<"+u.RequestURI()+`>; rel="`+link.rel+`"`)
+	}
+
+	if links != nil {
+		ctx.Header("Link", strings.Join(links, ", "))
+	}
+}
+
+// GetQueryParams retrieves and parses query parameters with validation and defaults.
+// Rules are given either as int1/str1 and int2/str2, or as repeated rule=<divisor>:<word> parameters.
+// The optional start and count restrict the result to a window of the sequence.
+func GetQueryParams(ctx *gin.Context, limits internal.LimitsConfig) (domain.FizzBuzzInput, errors.Error) {
+	rules, err := parseRules(ctx.QueryArray("rule"))
+	if err != nil {
+		return domain.FizzBuzzInput{}, err
+	}
+
+	int1, err := parseIntParam(ctx, "int1", rules != nil)
+	if err != nil {
+		return domain.FizzBuzzInput{}, err
+	}
+
+	int2, err := parseIntParam(ctx, "int2", rules != nil)
+	if err != nil {
+		return domain.FizzBuzzInput{}, err
 	}
 
 	limitStr := ctx.Query("limit")
-	// Set 100 has default limit if not provided
-	limit := 100
+	// Set the configured default limit if not provided
+	limit := limits.DefaultLimit
 	if limitStr != "" {
-		limit, err = strconv.Atoi(limitStr)
+		l, err := strconv.Atoi(limitStr)
 		if err != nil {
 			return domain.FizzBuzzInput{}, errors.BadRequest("failed_to_parse_limit", "failed to parse limit")
 		}
+		limit = l
 	}
 
 	str1 := ctx.Query("str1")
 	str2 := ctx.Query("str2")
 
+	start, err := parseIntParam(ctx, "start", true)
+	if err != nil {
+		return domain.FizzBuzzInput{}, err
+	}
+
+	count, err := parseIntParam(ctx, "count", true)
+	if err != nil {
+		return domain.FizzBuzzInput{}, err
+	}
+
 	return domain.FizzBuzzInput{
 		Int1:  int1,
 		Int2:  int2,
 		Limit: limit,
 		Str1:  str1,
 		Str2:  str2,
+		Rules: rules,
+		Start: start,
+		Count: count,
 	}, nil
 }
 
-// getFizzBuzzStatsEndpoint handles the FizzBuzz stats request
-func (c *fizzBuzzController) getFizzBuzzStatsEndpoint(ctx *gin.Context) {
-	fbRequest, err := c.fizzBuzzRepository.GetMostHits(ctx)
+// GetBodyParams decodes a JSON body with the same fields and defaults as the query parameters.
+// Unknown fields are rejected and the body size is capped.
+func GetBodyParams(ctx *gin.Context, limits internal.LimitsConfig) (domain.FizzBuzzInput, errors.Error) {
+	input := domain.FizzBuzzInput{Limit: limits.DefaultLimit}
+	if err := decodeBody(ctx, &input, limits.MaxBodySize); err != nil {
+		return domain.FizzBuzzInput{}, err
+	}
+
+	return input, nil
+}
+
+// GetBatchBodyParams decodes a JSON array of inputs, each one with the same fields and defaults as GetBodyParams
+func GetBatchBodyParams(ctx *gin.Context, limits internal.LimitsConfig) ([]domain.FizzBuzzInput, errors.Error) {
+	var raws []json.RawMessage
+	// The body of a batch may hold as many items as the body of a single request
+	if err := decodeBody(ctx, &raws, int64(limits.MaxBatchSize)*limits.MaxBodySize); err != nil {
+		return nil, err
+	}
+
+	if len(raws) == 0 || len(raws) > limits.MaxBatchSize {
+		return nil, errors.BadRequest("invalid_batch_size", "batch must contain between 1 and %d items", limits.MaxBatchSize)
+	}
+
+	inputs := make([]domain.FizzBuzzInput, len(raws))
+	for i, raw := range raws {
+		inputs[i] = domain.FizzBuzzInput{Limit: limits.DefaultLimit}
+		decoder := json.NewDecoder(bytes.NewReader(raw))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&inputs[i]); err != nil {
+			return nil, errors.BadRequest("failed_to_parse_body", "failed to parse body: item %d: %s", i, err.Error())
+		}
+	}
+
+	return inputs, nil
+}
+
+// decodeBody decodes a JSON body of at most maxSize bytes into v, rejecting unknown fields
+func decodeBody(ctx *gin.Context, v any, maxSize int64) errors.Error {
+	decoder := json.NewDecoder(http.MaxBytesReader(ctx.Writer, ctx.Request.Body, maxSize))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if stderrors.As(err, &maxBytesErr) {
+			return errors.RequestEntityTooLarge("body_too_large", "body must not exceed %d bytes", maxSize)
+		}
+
+		return errors.BadRequest("failed_to_parse_body", "failed to parse body: %s", err.Error())
+	}
+
+	if decoder.More() {
+		return errors.BadRequest("failed_to_parse_body", "failed to parse body: unexpected data after the JSON value")
+	}
+
+	return nil
+}
+
+// parseIntParam parses an integer query parameter, a missing parameter is 0 when optional
+func parseIntParam(ctx *gin.Context, name string, optional bool) (int, errors.Error) {
+	value := ctx.Query(name)
+	if value == "" && optional {
+		return 0, nil
+	}
+
+	i, err := strconv.Atoi(value)
 	if err != nil {
-		c.logger.Error("Failed to get most hits FizzBuzzRequest", zap.Error(err))
-		ctx.JSON(err.StatusCode(), gin.H{"error": err})
-		return
+		return 0, errors.BadRequest("failed_to_parse_"+name, "failed to parse %s", name)
+	}
+
+	return i, nil
+}
+
+// parseRules parses rule parameters of the form <divisor>:<word>, keeping their order
+func parseRules(values []string) (domain.Rules, errors.Error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+
+	rules := make(domain.Rules, 0, len(values))
+	for _, value := range values {
+		divisor, word, found := strings.Cut(value, ":")
+		if !found {
+			return nil, errors.BadRequest("failed_to_parse_rule", "failed to parse rule %q, expected <divisor>:<word>", value)
+		}
+
+		d, err := strconv.Atoi(divisor)
+		if err != nil {
+			return nil, errors.BadRequest("failed_to_parse_rule", "failed to parse rule %q, expected <divisor>:<word>", value)
+		}
+
+		rules = append(rules, domain.Rule{Divisor: d, Word: word})
 	}
 
-	ctx.JSON(http.StatusOK, fbRequest)
+	return rules, nil
 }
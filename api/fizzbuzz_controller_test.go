@@ -1,16 +1,19 @@
 package api_test
 
 import (
+	"context"
+	stderrors "errors"
 	"lbc/fizzbuzz/api"
-	"lbc/fizzbuzz/internal"
+	"lbc/fizzbuzz/domain"
 	"lbc/fizzbuzz/repository"
 	"lbc/fizzbuzz/service"
-	"lbc/fizzbuzz/testdata/utils"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/gin-gonic/gin"
+	"github.com/mwm-io/gapi/errors"
 	"github.com/stretchr/testify/assert"
 	"go.uber.org/zap"
 )
@@ -18,9 +21,9 @@ import (
 // TestFizzBuzzEndpointValidation tests input validation for FizzBuzz API
 func TestFizzBuzzEndpointValidation(t *testing.T) {
 	router := gin.Default()
-	fizzBuzzRepository := repository.NewFizzBuzzRepository(internal.Clients.PostgreSQL(), zap.NewExample())
+	fizzBuzzRepository := repository.NewMemoryFizzBuzzRepository()
 	fizzBuzzService := service.NewFizzBuzzService(fizzBuzzRepository)
-	api.SetupFizzBuzzController(zap.NewExample(), router, fizzBuzzService, fizzBuzzRepository)
+	api.SetupFizzBuzzController(zap.NewExample(), router, fizzBuzzService, limits, server)
 
 	tests := []struct {
 		name         string
@@ -58,6 +61,36 @@ func TestFizzBuzzEndpointValidation(t *testing.T) {
 			expectedCode: http.StatusBadRequest,
 			expectedBody: `"message":"str2 must not be empty","kind":"invalid_input"`,
 		},
+		{
+			name:         "Too long str1 parameter",
+			url:          "/api/v1/fizzbuzz?int1=3&int2=5&limit=15&str1=" + strings.Repeat("é", 51) + "&str2=buzz",
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `"message":"str1 must not be longer than 50 characters","kind":"invalid_input"`,
+		},
+		{
+			name:         "Longest str2 parameter",
+			url:          "/api/v1/fizzbuzz?int1=3&int2=5&limit=3&str1=fizz&str2=" + strings.Repeat("é", 50),
+			expectedCode: http.StatusOK,
+			expectedBody: `"result":"1,2,fizz"`,
+		},
+		{
+			name:         "Out of range int1 parameter",
+			url:          "/api/v1/fizzbuzz?int1=2147483648&int2=5&limit=15&str1=fizz&str2=buzz",
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `"message":"int1 must be between -2147483647 and 2147483647","kind":"invalid_input"`,
+		},
+		{
+			name:         "Too large limit parameter",
+			url:          "/api/v1/fizzbuzz?int1=3&int2=5&limit=2147483648&str1=fizz&str2=buzz",
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `"message":"limit must not be greater than 2147483647","kind":"invalid_input"`,
+		},
+		{
+			name:         "Too long word in rule list",
+			url:          "/api/v1/fizzbuzz?rule=3:fizz&rule=5:" + strings.Repeat("b", 51) + "&limit=15",
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `"message":"rules[1].word must not be longer than 50 characters","kind":"invalid_input"`,
+		},
 		{
 			name:         "Non-integer int1 parameter",
 			url:          "/api/v1/fizzbuzz?int1=abc&int2=5&limit=15&str1=fizz&str2=buzz",
@@ -76,6 +109,42 @@ func TestFizzBuzzEndpointValidation(t *testing.T) {
 			expectedCode: http.StatusBadRequest,
 			expectedBody: `"message":"failed to parse limit","kind":"failed_to_parse_limit"`,
 		},
+		{
+			name:         "Zero divisor in rule list",
+			url:          "/api/v1/fizzbuzz?rule=3:fizz&rule=0:buzz&limit=15",
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `"message":"rules[1].divisor must be different than 0","kind":"invalid_input"`,
+		},
+		{
+			name:         "Rule list combined with int1",
+			url:          "/api/v1/fizzbuzz?rule=3:fizz&int1=5&limit=15",
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `"message":"rules cannot be combined with int1, int2, str1 and str2","kind":"invalid_input"`,
+		},
+		{
+			name:         "Start beyond limit",
+			url:          "/api/v1/fizzbuzz?int1=3&int2=5&limit=15&str1=fizz&str2=buzz&start=16",
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `"message":"start must not be greater than limit","kind":"invalid_input"`,
+		},
+		{
+			name:         "Negative start",
+			url:          "/api/v1/fizzbuzz?int1=3&int2=5&limit=15&str1=fizz&str2=buzz&start=-1",
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `"message":"start must not be negative","kind":"invalid_input"`,
+		},
+		{
+			name:         "Negative count",
+			url:          "/api/v1/fizzbuzz?int1=3&int2=5&limit=15&str1=fizz&str2=buzz&count=-1",
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `"message":"count must not be negative","kind":"invalid_input"`,
+		},
+		{
+			name:         "Non-integer count parameter",
+			url:          "/api/v1/fizzbuzz?int1=3&int2=5&limit=15&str1=fizz&str2=buzz&count=abc",
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `"message":"failed to parse count","kind":"failed_to_parse_count"`,
+		},
 		{
 			name:         "Identical int1 and int2 parameters",
 			url:          "/api/v1/fizzbuzz?int1=3&int2=3&limit=15&str1=fizz&str2=buzz",
@@ -98,9 +167,9 @@ func TestFizzBuzzEndpointValidation(t *testing.T) {
 // TestFizzBuzzEndpointResults tests the FizzBuzz API output for various valid inputs
 func TestFizzBuzzEndpointResults(t *testing.T) {
 	router := gin.Default()
-	fizzBuzzRepository := repository.NewFizzBuzzRepository(internal.Clients.PostgreSQL(), zap.NewExample())
+	fizzBuzzRepository := repository.NewMemoryFizzBuzzRepository()
 	fizzBuzzService := service.NewFizzBuzzService(fizzBuzzRepository)
-	api.SetupFizzBuzzController(zap.NewExample(), router, fizzBuzzService, fizzBuzzRepository)
+	api.SetupFizzBuzzController(zap.NewExample(), router, fizzBuzzService, limits, server)
 
 	tests := []struct {
 		name         string
@@ -132,6 +201,30 @@ func TestFizzBuzzEndpointResults(t *testing.T) {
 			expectedCode: http.StatusOK,
 			expectedBody: `"result":"1,fizz,3,fizz,5,fizz,buzz,fizz,9,fizz,11,fizz,13,fizzbuzz,15,fizz,17,fizz,19,fizz"`,
 		},
+		{
+			name:         "Three rules",
+			url:          "/api/v1/fizzbuzz?rule=3:fizz&rule=5:buzz&rule=7:bazz&limit=21",
+			expectedCode: http.StatusOK,
+			expectedBody: `"result":"1,2,fizz,4,buzz,fizz,bazz,8,fizz,buzz,11,fizz,13,bazz,fizzbuzz,16,17,fizz,19,buzz,fizzbazz"`,
+		},
+		{
+			name:         "First window",
+			url:          "/api/v1/fizzbuzz?int1=3&int2=5&limit=20&str1=fizz&str2=buzz&count=5",
+			expectedCode: http.StatusOK,
+			expectedBody: `{"result":"1,2,fizz,4,buzz","next":6}`,
+		},
+		{
+			name:         "Middle window",
+			url:          "/api/v1/fizzbuzz?int1=3&int2=5&limit=20&str1=fizz&str2=buzz&start=11&count=5",
+			expectedCode: http.StatusOK,
+			expectedBody: `{"result":"11,fizz,13,14,fizzbuzz","next":16,"prev":6}`,
+		},
+		{
+			name:         "Last window truncated to limit",
+			url:          "/api/v1/fizzbuzz?int1=3&int2=5&limit=20&str1=fizz&str2=buzz&start=18&count=5",
+			expectedCode: http.StatusOK,
+			expectedBody: `{"result":"fizz,19,buzz","prev":13}`,
+		},
 		{
 			name:         "Only multiples of int1",
 			url:          "/api/v1/fizzbuzz?int1=2&int2=11&limit=10&str1=foo&str2=bar",
@@ -151,15 +244,383 @@ func TestFizzBuzzEndpointResults(t *testing.T) {
 	}
 }
 
-func TestGetFizzBuzzStatsEndpoint(t *testing.T) {
+// TestFizzBuzzEndpointPost tests the FizzBuzz API with parameters given as a JSON body
+func TestFizzBuzzEndpointPost(t *testing.T) {
+	router := gin.Default()
+	fizzBuzzRepository := repository.NewMemoryFizzBuzzRepository()
+	fizzBuzzService := service.NewFizzBuzzService(fizzBuzzRepository)
+	api.SetupFizzBuzzController(zap.NewExample(), router, fizzBuzzService, limits, server)
+
+	tests := []struct {
+		name         string
+		url          string
+		body         string
+		expectedCode int
+		expectedBody string
+	}{
+		{
+			name:         "Valid body",
+			url:          "/api/v1/fizzbuzz",
+			body:         `{"int1": 3, "int2": 5, "limit": 15, "str1": "fizz", "str2": "buzz"}`,
+			expectedCode: http.StatusOK,
+			expectedBody: `{"result":"1,2,fizz,4,buzz,fizz,7,8,fizz,buzz,11,fizz,13,14,fizzbuzz"}`,
+		},
+		{
+			name:         "Rule list and output format",
+			url:          "/api/v1/fizzbuzz/?format=array",
+			body:         `{"rules": [{"divisor": 2, "word": "ü"}, {"divisor": 3, "word": "ß"}], "limit": 6}`,
+			expectedCode: http.StatusOK,
+			expectedBody: `["1","ü","ß","ü","5","üß"]`,
+		},
+		{
+			name:         "Same validation as query parameters",
+			url:          "/api/v1/fizzbuzz",
+			body:         `{"int1": 3, "int2": 3, "limit": 15, "str1": "fizz", "str2": "buzz"}`,
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `{"error":{"message":"int1 and int2 must be different","kind":"invalid_input"}}`,
+		},
+		{
+			name:         "Unknown field",
+			url:          "/api/v1/fizzbuzz",
+			body:         `{"int1": 3, "int2": 5, "limit": 15, "str1": "fizz", "str2": "buzz", "str3": "bazz"}`,
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `{"error":{"message":"failed to parse body: json: unknown field \"str3\"","kind":"failed_to_parse_body"}}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, tt.url, strings.NewReader(tt.body))
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			assert.Equal(t, tt.expectedCode, w.Code)
+			assert.Equal(t, tt.expectedBody, w.Body.String())
+		})
+	}
+}
+
+// TestFizzBuzzBatchEndpoint tests the FizzBuzz batch API
+func TestFizzBuzzBatchEndpoint(t *testing.T) {
+	router := gin.Default()
+	fizzBuzzRepository := repository.NewMemoryFizzBuzzRepository()
+	fizzBuzzService := service.NewFizzBuzzService(fizzBuzzRepository)
+	api.SetupFizzBuzzController(zap.NewExample(), router, fizzBuzzService, limits, server)
+
+	tests := []struct {
+		name         string
+		body         string
+		expectedCode int
+		expectedBody string
+	}{
+		{
+			name: "Results and errors in order",
+			body: `[
+				{"int1": 3, "int2": 5, "limit": 5, "str1": "fizz", "str2": "buzz"},
+				{"int1": 0, "int2": 5, "limit": 5, "str1": "fizz", "str2": "buzz"},
+				{"rules": [{"divisor": 2, "word": "foo"}], "limit": 10, "count": 4}
+			]`,
+			expectedCode: http.StatusOK,
+			expectedBody: `[{"result":"1,2,fizz,4,buzz"},` +
+				`{"error":{"message":"int1 must be different than 0","kind":"invalid_input"}},` +
+				`{"result":"1,foo,3,foo","next":5}]`,
+		},
+		{
+			name:         "Empty batch",
+			body:         `[]`,
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `{"error":{"message":"batch must contain between 1 and 100 items","kind":"invalid_batch_size"}}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/fizzbuzz/batch", strings.NewReader(tt.body))
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			assert.Equal(t, tt.expectedCode, w.Code)
+			assert.Equal(t, tt.expectedBody, w.Body.String())
+		})
+	}
+}
+
+// TestFizzBuzzEndpointStream tests the streaming mode of the FizzBuzz API
+func TestFizzBuzzEndpointStream(t *testing.T) {
+	router := gin.Default()
+	fizzBuzzRepository := repository.NewMemoryFizzBuzzRepository()
+	fizzBuzzService := service.NewFizzBuzzService(fizzBuzzRepository)
+	api.SetupFizzBuzzController(zap.NewExample(), router, fizzBuzzService, limits, server)
+
+	tests := []struct {
+		name                string
+		url                 string
+		expectedCode        int
+		expectedContentType string
+		expectedBody        string
+	}{
+		{
+			name:                "Plain text stream",
+			url:                 "/api/v1/fizzbuzz?int1=3&int2=5&limit=5&str1=fizz&str2=buzz&stream=text",
+			expectedCode:        http.StatusOK,
+			expectedContentType: "text/plain; charset=utf-8",
+			expectedBody:        "1\n2\nfizz\n4\nbuzz\n",
+		},
+		{
+			name:                "NDJSON stream",
+			url:                 "/api/v1/fizzbuzz?int1=3&int2=5&limit=3&str1=fizz&str2=buzz&stream=ndjson",
+			expectedCode:        http.StatusOK,
+			expectedContentType: "application/x-ndjson",
+			expectedBody:        "{\"n\":1,\"value\":\"1\",\"kind\":\"number\"}\n{\"n\":2,\"value\":\"2\",\"kind\":\"number\"}\n{\"n\":3,\"value\":\"fizz\",\"kind\":\"word\"}\n",
+		},
+		{
+			name:                "Invalid input is reported before streaming",
+			url:                 "/api/v1/fizzbuzz?int1=0&int2=5&limit=3&str1=fizz&str2=buzz&stream=text",
+			expectedCode:        http.StatusBadRequest,
+			expectedContentType: "application/json; charset=utf-8",
+			expectedBody:        `{"error":{"message":"int1 must be different than 0","kind":"invalid_input"}}`,
+		},
+		{
+			name:                "Unknown stream format",
+			url:                 "/api/v1/fizzbuzz?int1=3&int2=5&limit=3&str1=fizz&str2=buzz&stream=yaml",
+			expectedCode:        http.StatusBadRequest,
+			expectedContentType: "application/json; charset=utf-8",
+			expectedBody:        `{"error":{"message":"stream must be one of array, csv, json, ndjson, objects, text, xml","kind":"invalid_stream_format"}}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.url, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			assert.Equal(t, tt.expectedCode, w.Code)
+			assert.Equal(t, tt.expectedContentType, w.Header().Get("Content-Type"))
+			assert.Equal(t, tt.expectedBody, w.Body.String())
+		})
+	}
+}
+
+// failingSaveRepository fails to record any request
+type failingSaveRepository struct {
+	repository.FizzBuzzRepository
+}
+
+func (failingSaveRepository) Save(context.Context, domain.FizzBuzzInput) errors.Error {
+	return errors.Wrap(stderrors.New("the database is gone"))
+}
+
+// TestFizzBuzzEndpointStreamSaveFailure tests that a sequence fully streamed before failing to be recorded still ends well-formed
+func TestFizzBuzzEndpointStreamSaveFailure(t *testing.T) {
+	router := gin.Default()
+	fizzBuzzService := service.NewFizzBuzzService(failingSaveRepository{repository.NewMemoryFizzBuzzRepository()})
+	api.SetupFizzBuzzController(zap.NewExample(), router, fizzBuzzService, limits, server)
+
+	tests := []struct {
+		name         string
+		url          string
+		expectedCode int
+		expectedBody string
+	}{
+		{
+			name:         "Stream",
+			url:          "/api/v1/fizzbuzz?int1=3&int2=5&limit=3&str1=fizz&str2=buzz&stream=array",
+			expectedCode: http.StatusOK,
+			expectedBody: `["1","2","fizz"]`,
+		},
+		{
+			name:         "Format",
+			url:          "/api/v1/fizzbuzz?int1=3&int2=5&limit=3&str1=fizz&str2=buzz&format=array",
+			expectedCode: http.StatusInternalServerError,
+			expectedBody: `"kind":"internal_error"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.url, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			assert.Equal(t, tt.expectedCode, w.Code)
+			assert.Contains(t, w.Body.String(), tt.expectedBody)
+		})
+	}
+}
+
+// TestFizzBuzzEndpointWindowLinks tests the Link header to the adjacent windows, sent with every format
+func TestFizzBuzzEndpointWindowLinks(t *testing.T) {
 	router := gin.Default()
-	db := internal.Clients.PostgreSQL()
-	fizzBuzzRepository := repository.NewFizzBuzzRepository(db, zap.NewExample())
+	fizzBuzzRepository := repository.NewMemoryFizzBuzzRepository()
 	fizzBuzzService := service.NewFizzBuzzService(fizzBuzzRepository)
-	api.SetupFizzBuzzController(zap.NewExample(), router, fizzBuzzService, fizzBuzzRepository)
+	api.SetupFizzBuzzController(zap.NewExample(), router, fizzBuzzService, limits, server)
 
-	err := utils.LoadFixtures(db)
-	assert.Nil(t, err)
+	tests := []struct {
+		name         string
+		url          string
+		expectedLink string
+	}{
+		{
+			name:         "Default format",
+			url:          "/api/v1/fizzbuzz?int1=3&int2=5&limit=20&str1=fizz&str2=buzz&start=11&count=5",
+			expectedLink: `</api/v1/fizzbuzz?count=5&int1=3&int2=5&limit=20&start=16&str1=fizz&str2=buzz>; rel="next", </api/v1/fizzbuzz?count=5&int1=3&int2=5&limit=20&start=6&str1=fizz&str2=buzz>; rel="prev"`,
+		},
+		{
+			name:         "Text format",
+			url:          "/api/v1/fizzbuzz?int1=3&int2=5&limit=20&str1=fizz&str2=buzz&count=5&format=text",
+			expectedLink: `</api/v1/fizzbuzz?count=5&format=text&int1=3&int2=5&limit=20&start=6&str1=fizz&str2=buzz>; rel="next"`,
+		},
+		{
+			name:         "Stream",
+			url:          "/api/v1/fizzbuzz?rule=3:fizz&rule=5:buzz&limit=20&start=18&count=5&stream=csv",
+			expectedLink: `</api/v1/fizzbuzz?count=5&limit=20&rule=3%3Afizz&rule=5%3Abuzz&start=13&stream=csv>; rel="prev"`,
+		},
+		{
+			name: "Whole sequence",
+			url:  "/api/v1/fizzbuzz?int1=3&int2=5&limit=20&str1=fizz&str2=buzz&format=csv",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.url, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, tt.expectedLink, w.Header().Get("Link"))
+		})
+	}
+}
+
+// TestFizzBuzzEndpointFormats tests the content negotiation of the FizzBuzz API
+func TestFizzBuzzEndpointFormats(t *testing.T) {
+	router := gin.Default()
+	fizzBuzzRepository := repository.NewMemoryFizzBuzzRepository()
+	fizzBuzzService := service.NewFizzBuzzService(fizzBuzzRepository)
+	api.SetupFizzBuzzController(zap.NewExample(), router, fizzBuzzService, limits, server)
+
+	tests := []struct {
+		name                string
+		url                 string
+		accept              string
+		expectedCode        int
+		expectedContentType string
+		expectedBody        string
+	}{
+		{
+			name:                "Default format",
+			url:                 "/api/v1/fizzbuzz?int1=3&int2=5&limit=5&str1=fizz&str2=buzz",
+			accept:              "application/json",
+			expectedCode:        http.StatusOK,
+			expectedContentType: "application/json; charset=utf-8",
+			expectedBody:        `{"result":"1,2,fizz,4,buzz"}`,
+		},
+		{
+			name:                "JSON array of terms",
+			url:                 "/api/v1/fizzbuzz?int1=3&int2=5&limit=5&str1=a,b&str2=buzz&format=array",
+			expectedCode:        http.StatusOK,
+			expectedContentType: "application/json; charset=utf-8",
+			expectedBody:        `["1","2","a,b","4","buzz"]`,
+		},
+		{
+			name:                "JSON array of typed terms",
+			url:                 "/api/v1/fizzbuzz?int1=1&int2=2&limit=2&str1=fizz&str2=buzz&format=objects",
+			expectedCode:        http.StatusOK,
+			expectedContentType: "application/json; charset=utf-8",
+			expectedBody:        `[{"n":1,"value":"fizz","kind":"word"},{"n":2,"value":"fizzbuzz","kind":"combined"}]`,
+		},
+		{
+			name:                "CSV from Accept header",
+			url:                 "/api/v1/fizzbuzz?int1=3&int2=5&limit=3&str1=a,b&str2=buzz",
+			accept:              "text/csv",
+			expectedCode:        http.StatusOK,
+			expectedContentType: "text/csv; charset=utf-8",
+			expectedBody:        "n,value,kind\r\n1,1,number\r\n2,2,number\r\n3,\"a,b\",word\r\n",
+		},
+		{
+			name:                "Plain text from Accept header",
+			url:                 "/api/v1/fizzbuzz?int1=3&int2=5&limit=3&str1=fizz&str2=buzz",
+			accept:              "text/plain",
+			expectedCode:        http.StatusOK,
+			expectedContentType: "text/plain; charset=utf-8",
+			expectedBody:        "1\n2\nfizz\n",
+		},
+		{
+			name:                "XML from Accept header",
+			url:                 "/api/v1/fizzbuzz?int1=3&int2=5&limit=3&str1=fizz&str2=buzz",
+			accept:              "application/xml",
+			expectedCode:        http.StatusOK,
+			expectedContentType: "application/xml; charset=utf-8",
+			expectedBody: `<?xml version="1.0" encoding="UTF-8"?>` + "\n" +
+				`<fizzbuzz><term n="1" kind="number">1</term><term n="2" kind="number">2</term><term n="3" kind="word">fizz</term></fizzbuzz>`,
+		},
+		{
+			name:                "Format parameter takes precedence over Accept header",
+			url:                 "/api/v1/fizzbuzz?int1=3&int2=5&limit=3&str1=fizz&str2=buzz&format=text",
+			accept:              "application/xml",
+			expectedCode:        http.StatusOK,
+			expectedContentType: "text/plain; charset=utf-8",
+			expectedBody:        "1\n2\nfizz\n",
+		},
+		{
+			name:                "Unknown format",
+			url:                 "/api/v1/fizzbuzz?int1=3&int2=5&limit=3&str1=fizz&str2=buzz&format=yaml",
+			expectedCode:        http.StatusBadRequest,
+			expectedContentType: "application/json; charset=utf-8",
+			expectedBody:        `{"error":{"message":"format must be one of array, csv, json, ndjson, objects, text, xml","kind":"invalid_format"}}`,
+		},
+		{
+			name:                "Unsupported media type gets the default format",
+			url:                 "/api/v1/fizzbuzz?int1=3&int2=5&limit=3&str1=fizz&str2=buzz",
+			accept:              "image/png",
+			expectedCode:        http.StatusOK,
+			expectedContentType: "application/json; charset=utf-8",
+			expectedBody:        `{"result":"1,2,fizz"}`,
+		},
+		{
+			name:                "Browser gets the default format",
+			url:                 "/api/v1/fizzbuzz?int1=3&int2=5&limit=3&str1=fizz&str2=buzz",
+			accept:              "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8",
+			expectedCode:        http.StatusOK,
+			expectedContentType: "application/json; charset=utf-8",
+			expectedBody:        `{"result":"1,2,fizz"}`,
+		},
+		{
+			name:                "JSON listed first",
+			url:                 "/api/v1/fizzbuzz?int1=3&int2=5&limit=3&str1=fizz&str2=buzz",
+			accept:              "application/json, text/csv",
+			expectedCode:        http.StatusOK,
+			expectedContentType: "application/json; charset=utf-8",
+			expectedBody:        `{"result":"1,2,fizz"}`,
+		},
+		{
+			name:                "Highest quality wins",
+			url:                 "/api/v1/fizzbuzz?int1=3&int2=5&limit=3&str1=fizz&str2=buzz",
+			accept:              "application/json;q=0.5, text/plain",
+			expectedCode:        http.StatusOK,
+			expectedContentType: "text/plain; charset=utf-8",
+			expectedBody:        "1\n2\nfizz\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.url, nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			assert.Equal(t, tt.expectedCode, w.Code)
+			assert.Equal(t, tt.expectedContentType, w.Header().Get("Content-Type"))
+			assert.Equal(t, tt.expectedBody, w.Body.String())
+		})
+	}
+}
+
+// TestFizzBuzzTermEndpoint tests the single term FizzBuzz API
+func TestFizzBuzzTermEndpoint(t *testing.T) {
+	router := gin.Default()
+	fizzBuzzRepository := repository.NewMemoryFizzBuzzRepository()
+	fizzBuzzService := service.NewFizzBuzzService(fizzBuzzRepository)
+	api.SetupFizzBuzzController(zap.NewExample(), router, fizzBuzzService, limits, server)
 
 	tests := []struct {
 		name         string
@@ -168,10 +629,28 @@ func TestGetFizzBuzzStatsEndpoint(t *testing.T) {
 		expectedBody string
 	}{
 		{
-			name:         "Successful Stats Fetch",
-			url:          "/api/v1/fizzbuzz/stats",
+			name:         "Term far beyond the limit",
+			url:          "/api/v1/fizzbuzz/term?int1=3&int2=5&str1=fizz&str2=buzz&n=987654321",
 			expectedCode: http.StatusOK,
-			expectedBody: `"int1":3,"int2":5,"limit":100,"str1":"fizz","str2":"buzz","hits":42`,
+			expectedBody: `{"n":987654321,"value":"fizz","kind":"word"}`,
+		},
+		{
+			name:         "Term with rule list",
+			url:          "/api/v1/fizzbuzz/term?rule=3:fizz&rule=5:buzz&rule=7:bazz&n=35",
+			expectedCode: http.StatusOK,
+			expectedBody: `{"n":35,"value":"buzzbazz","kind":"combined"}`,
+		},
+		{
+			name:         "Missing n parameter",
+			url:          "/api/v1/fizzbuzz/term?int1=3&int2=5&str1=fizz&str2=buzz",
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `"message":"failed to parse n","kind":"failed_to_parse_n"`,
+		},
+		{
+			name:         "Negative n parameter",
+			url:          "/api/v1/fizzbuzz/term?int1=3&int2=5&str1=fizz&str2=buzz&n=-1",
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `"message":"n must be greater than 0","kind":"invalid_input"`,
 		},
 	}
 
@@ -180,9 +659,144 @@ func TestGetFizzBuzzStatsEndpoint(t *testing.T) {
 			req := httptest.NewRequest(http.MethodGet, tt.url, nil)
 			w := httptest.NewRecorder()
 			router.ServeHTTP(w, req)
+			assert.Equal(t, tt.expectedCode, w.Code)
+			assert.Contains(t, w.Body.String(), tt.expectedBody)
+		})
+	}
+}
 
+// TestFizzBuzzCountsEndpoint tests the FizzBuzz counts API
+func TestFizzBuzzCountsEndpoint(t *testing.T) {
+	router := gin.Default()
+	fizzBuzzRepository := repository.NewMemoryFizzBuzzRepository()
+	fizzBuzzService := service.NewFizzBuzzService(fizzBuzzRepository)
+	api.SetupFizzBuzzController(zap.NewExample(), router, fizzBuzzService, limits, server)
+
+	tests := []struct {
+		name         string
+		url          string
+		expectedCode int
+		expectedBody string
+	}{
+		{
+			name:         "Counts for int1 and int2",
+			url:          "/api/v1/fizzbuzz/counts?int1=3&int2=5&limit=100&str1=fizz&str2=buzz",
+			expectedCode: http.StatusOK,
+			expectedBody: `"plain":53,`,
+		},
+		{
+			name:         "Category shortcuts for int1 and int2",
+			url:          "/api/v1/fizzbuzz/counts?int1=3&int2=5&limit=100&str1=fizz&str2=buzz",
+			expectedCode: http.StatusOK,
+			expectedBody: `"str1":27,"str2":14,"both":6}`,
+		},
+		{
+			name:         "Same word for both rules",
+			url:          "/api/v1/fizzbuzz/counts?int1=5&int2=3&limit=100&str1=fizz&str2=fizz",
+			expectedCode: http.StatusOK,
+			expectedBody: `"str1":14,"str2":27,"both":6}`,
+		},
+		{
+			name:         "Counts for a rule list",
+			url:          "/api/v1/fizzbuzz/counts?rule=3:fizz&rule=5:buzz&limit=15",
+			expectedCode: http.StatusOK,
+			expectedBody: `{"first":1,"last":15,"plain":8,"combinations":[{"rules":[0],"words":["fizz"],"value":"fizz","count":4},{"rules":[1],"words":["buzz"],"value":"buzz","count":2},{"rules":[0,1],"words":["fizz","buzz"],"value":"fizzbuzz","count":1}]}`,
+		},
+		{
+			name:         "Invalid limit",
+			url:          "/api/v1/fizzbuzz/counts?int1=3&int2=5&limit=0&str1=fizz&str2=buzz",
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `"message":"limit must be greater than 0","kind":"invalid_input"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.url, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
 			assert.Equal(t, tt.expectedCode, w.Code)
 			assert.Contains(t, w.Body.String(), tt.expectedBody)
 		})
 	}
 }
+
+// TestFizzBuzzEndpointMaxTerms tests that sequences built in memory are bounded, streamed ones excepted
+func TestFizzBuzzEndpointMaxTerms(t *testing.T) {
+	router := gin.Default()
+	bounded := limits
+	bounded.MaxTerms = 10
+	api.SetupFizzBuzzController(zap.NewExample(), router, service.NewFizzBuzzService(repository.NewMemoryFizzBuzzRepository()), bounded, server)
+
+	tests := []struct {
+		name         string
+		method       string
+		url          string
+		body         string
+		expectedCode int
+		expectedBody string
+	}{
+		{
+			name:         "Too many terms",
+			method:       http.MethodGet,
+			url:          "/api/v1/fizzbuzz?int1=3&int2=5&limit=15&str1=fizz&str2=buzz",
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `"message":"at most 10 terms are returned at once, use start and count or stream the sequence","kind":"too_many_terms"`,
+		},
+		{
+			name:         "Too many terms in another format",
+			method:       http.MethodGet,
+			url:          "/api/v1/fizzbuzz?int1=3&int2=5&limit=15&str1=fizz&str2=buzz&format=csv",
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `"kind":"too_many_terms"`,
+		},
+		{
+			name:         "Too many terms in a body",
+			method:       http.MethodPost,
+			url:          "/api/v1/fizzbuzz",
+			body:         `{"int1":3,"int2":5,"limit":15,"str1":"fizz","str2":"buzz"}`,
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `"kind":"too_many_terms"`,
+		},
+		{
+			name:         "Too many terms in a batch",
+			method:       http.MethodPost,
+			url:          "/api/v1/fizzbuzz/batch",
+			body:         `[{"int1":3,"int2":5,"limit":10,"str1":"fizz","str2":"buzz"},{"int1":3,"int2":5,"limit":15,"str1":"fizz","str2":"buzz"}]`,
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `"message":"batch[1]: at most 10 terms are returned at once, use start and count or stream the sequence","kind":"too_many_terms"`,
+		},
+		{
+			name:         "Window within the bound",
+			method:       http.MethodGet,
+			url:          "/api/v1/fizzbuzz?int1=3&int2=5&limit=1000000&str1=fizz&str2=buzz&start=11&count=5",
+			expectedCode: http.StatusOK,
+			expectedBody: `"result":"11,fizz,13,14,fizzbuzz"`,
+		},
+		{
+			name:         "Invalid input reported first",
+			method:       http.MethodGet,
+			url:          "/api/v1/fizzbuzz?int1=0&int2=5&limit=15&str1=fizz&str2=buzz",
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `"kind":"invalid_input"`,
+		},
+		{
+			name:         "Streamed sequence",
+			method:       http.MethodGet,
+			url:          "/api/v1/fizzbuzz?int1=3&int2=5&limit=15&str1=fizz&str2=buzz&stream=text",
+			expectedCode: http.StatusOK,
+			expectedBody: "fizzbuzz",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(tt.method, tt.url, strings.NewReader(tt.body))
+			resp := httptest.NewRecorder()
+			router.ServeHTTP(resp, req)
+
+			assert.Equal(t, tt.expectedCode, resp.Code)
+			assert.Contains(t, resp.Body.String(), tt.expectedBody)
+		})
+	}
+}
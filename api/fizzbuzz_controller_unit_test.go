@@ -3,15 +3,26 @@ package api_test
 import (
 	"lbc/fizzbuzz/api"
 	"lbc/fizzbuzz/domain"
+	"lbc/fizzbuzz/internal"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
+var (
+	// limits are the default limits of the configuration, which the API is tested with
+	limits = internal.LimitsConfig{DefaultLimit: 100, MaxBodySize: 64 * 1024, MaxBatchSize: 100, MaxTerms: 1_000_000}
+	// server is the configuration of the server the API is tested with
+	server = internal.ServerConfig{WriteTimeout: time.Minute}
+)
+
 func TestGetQueryParams(t *testing.T) {
 	tests := []struct {
 		name      string
@@ -80,6 +91,43 @@ func TestGetQueryParams(t *testing.T) {
 			expected:  domain.FizzBuzzInput{Int1: 3, Int2: 5, Limit: -15, Str1: "fizz", Str2: "buzz"},
 			expectErr: false,
 		},
+		{
+			name: "Rule list",
+			query: map[string]string{
+				"rule": "3:fizz", "limit": "15",
+			},
+			expected:  domain.FizzBuzzInput{Limit: 15, Rules: domain.Rules{{Divisor: 3, Word: "fizz"}}},
+			expectErr: false,
+		},
+		{
+			name: "Invalid rule - missing word separator",
+			query: map[string]string{
+				"rule": "3fizz", "limit": "15",
+			},
+			expectErr: true,
+		},
+		{
+			name: "Invalid rule - non-integer divisor",
+			query: map[string]string{
+				"rule": "abc:fizz", "limit": "15",
+			},
+			expectErr: true,
+		},
+		{
+			name: "Window parameters",
+			query: map[string]string{
+				"int1": "3", "int2": "5", "limit": "100", "str1": "fizz", "str2": "buzz", "start": "11", "count": "10",
+			},
+			expected:  domain.FizzBuzzInput{Int1: 3, Int2: 5, Limit: 100, Str1: "fizz", Str2: "buzz", Start: 11, Count: 10},
+			expectErr: false,
+		},
+		{
+			name: "Invalid start - non-integer value 'ddd'",
+			query: map[string]string{
+				"int1": "3", "int2": "5", "limit": "100", "str1": "fizz", "str2": "buzz", "start": "ddd",
+			},
+			expectErr: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -96,7 +144,7 @@ func TestGetQueryParams(t *testing.T) {
 			}
 
 			ctx.Request.URL.RawQuery = q.Encode()
-			result, err := api.GetQueryParams(ctx)
+			result, err := api.GetQueryParams(ctx, limits)
 
 			if tt.expectErr {
 				require.Error(t, err)
@@ -108,3 +156,124 @@ func TestGetQueryParams(t *testing.T) {
 		})
 	}
 }
+
+func TestGetBodyParams(t *testing.T) {
+	tests := []struct {
+		name         string
+		body         string
+		expected     domain.FizzBuzzInput
+		expectedKind string
+	}{
+		{
+			name:     "Valid parameters",
+			body:     `{"int1": 3, "int2": 5, "limit": 15, "str1": "fizz", "str2": "buzz"}`,
+			expected: domain.FizzBuzzInput{Int1: 3, Int2: 5, Limit: 15, Str1: "fizz", Str2: "buzz"},
+		},
+		{
+			name:     "Missing limit - should use default",
+			body:     `{"int1": 3, "int2": 5, "str1": "fizz", "str2": "buzz"}`,
+			expected: domain.FizzBuzzInput{Int1: 3, Int2: 5, Limit: 100, Str1: "fizz", Str2: "buzz"},
+		},
+		{
+			name: "Rule list with unicode words",
+			body: `{"rules": [{"divisor": 3, "word": "フィズ"}, {"divisor": 5, "word": "ブズ"}], "limit": 15}`,
+			expected: domain.FizzBuzzInput{Limit: 15, Rules: domain.Rules{
+				{Divisor: 3, Word: "フィズ"}, {Divisor: 5, Word: "ブズ"},
+			}},
+		},
+		{
+			name:         "Unknown field",
+			body:         `{"int1": 3, "int2": 5, "str1": "fizz", "str2": "buzz", "int3": 7}`,
+			expectedKind: "failed_to_parse_body",
+		},
+		{
+			name:         "Wrong type",
+			body:         `{"int1": "3", "int2": 5, "str1": "fizz", "str2": "buzz"}`,
+			expectedKind: "failed_to_parse_body",
+		},
+		{
+			name:         "Trailing data",
+			body:         `{"int1": 3, "int2": 5, "str1": "fizz", "str2": "buzz"} {}`,
+			expectedKind: "failed_to_parse_body",
+		},
+		{
+			name:         "Body too large",
+			body:         `{"str1": "` + strings.Repeat("a", 64*1024) + `"}`,
+			expectedKind: "body_too_large",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+			ctx.Request = httptest.NewRequest(http.MethodPost, "/api/v1/fizzbuzz", strings.NewReader(tt.body))
+
+			result, err := api.GetBodyParams(ctx, limits)
+
+			if tt.expectedKind != "" {
+				require.Error(t, err)
+				assert.Equal(t, tt.expectedKind, err.Kind())
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestGetBatchBodyParams(t *testing.T) {
+	tests := []struct {
+		name         string
+		body         string
+		expected     []domain.FizzBuzzInput
+		expectedKind string
+	}{
+		{
+			name: "Valid items with defaults",
+			body: `[{"int1": 3, "int2": 5, "str1": "fizz", "str2": "buzz"}, {"rules": [{"divisor": 2, "word": "foo"}], "limit": 10}]`,
+			expected: []domain.FizzBuzzInput{
+				{Int1: 3, Int2: 5, Limit: 100, Str1: "fizz", Str2: "buzz"},
+				{Limit: 10, Rules: domain.Rules{{Divisor: 2, Word: "foo"}}},
+			},
+		},
+		{
+			name:         "Empty batch",
+			body:         `[]`,
+			expectedKind: "invalid_batch_size",
+		},
+		{
+			name:         "Too many items",
+			body:         "[" + strings.Repeat(`{"int1": 3},`, 100) + `{"int1": 3}]`,
+			expectedKind: "invalid_batch_size",
+		},
+		{
+			name:         "Not an array",
+			body:         `{"int1": 3, "int2": 5, "str1": "fizz", "str2": "buzz"}`,
+			expectedKind: "failed_to_parse_body",
+		},
+		{
+			name:         "Unknown field in an item",
+			body:         `[{"int1": 3, "int2": 5, "str1": "fizz", "str2": "buzz"}, {"int3": 7}]`,
+			expectedKind: "failed_to_parse_body",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+			ctx.Request = httptest.NewRequest(http.MethodPost, "/api/v1/fizzbuzz/batch", strings.NewReader(tt.body))
+
+			result, err := api.GetBatchBodyParams(ctx, limits)
+
+			if tt.expectedKind != "" {
+				require.Error(t, err)
+				assert.Equal(t, tt.expectedKind, err.Kind())
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, tt.expected, result)
+			}
+		})
+	}
+}
@@ -0,0 +1,318 @@
+package api
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"lbc/fizzbuzz/domain"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mwm-io/gapi/errors"
+)
+
+// defaultFormat is the historical {"result": "1,2,fizz"} response
+const defaultFormat = "json"
+
+// termEncoder encodes a sequence of terms, Close must be called after the last term
+type termEncoder interface {
+	Encode(term domain.Term) error
+	Close() error
+}
+
+// termFormat describes an output format of the sequence
+type termFormat struct {
+	contentType string
+	newEncoder  func(w io.Writer) termEncoder
+}
+
+// termFormats maps the accepted format names to their description
+var termFormats = map[string]termFormat{
+	defaultFormat: {
+		contentType: "application/json; charset=utf-8",
+		newEncoder:  func(w io.Writer) termEncoder { return &joinedJSONEncoder{w: w} },
+	},
+	"array": {
+		contentType: "application/json; charset=utf-8",
+		newEncoder: func(w io.Writer) termEncoder {
+			return &jsonArrayEncoder{w: w, value: func(term domain.Term) any { return term.Value }}
+		},
+	},
+	"objects": {
+		contentType: "application/json; charset=utf-8",
+		newEncoder: func(w io.Writer) termEncoder {
+			return &jsonArrayEncoder{w: w, value: func(term domain.Term) any { return term }}
+		},
+	},
+	"ndjson": {
+		contentType: "application/x-ndjson",
+		newEncoder:  func(w io.Writer) termEncoder { return &ndjsonEncoder{enc: json.NewEncoder(w)} },
+	},
+	"text": {
+		contentType: "text/plain; charset=utf-8",
+		newEncoder:  func(w io.Writer) termEncoder { return &textEncoder{w: w} },
+	},
+	"csv": {
+		contentType: "text/csv; charset=utf-8",
+		newEncoder:  newCSVEncoder,
+	},
+	"xml": {
+		contentType: "application/xml; charset=utf-8",
+		newEncoder:  func(w io.Writer) termEncoder { return &xmlEncoder{w: w, enc: xml.NewEncoder(w)} },
+	},
+}
+
+// acceptedMediaTypes maps the media types understood in the Accept header to a format name
+var acceptedMediaTypes = map[string]string{
+	"application/json":     defaultFormat,
+	"application/x-ndjson": "ndjson",
+	"text/plain":           "text",
+	"text/csv":             "csv",
+	"application/xml":      "xml",
+	"text/xml":             "xml",
+}
+
+// negotiateFormat returns the format requested by the format query parameter, or else by the Accept header.
+// The historical JSON shape stays the default, for clients sending no Accept header or none of the supported media types.
+func negotiateFormat(ctx *gin.Context) (string, errors.Error) {
+	if format := ctx.Query("format"); format != "" {
+		if _, ok := termFormats[format]; !ok {
+			return "", errors.BadRequest("invalid_format", "format must be one of %s", formatNames())
+		}
+		return format, nil
+	}
+
+	return acceptedFormat(ctx.GetHeader("Accept")), nil
+}
+
+// acceptedFormat returns the format of the media type the client prefers in an Accept header:
+// the first one listed with the highest quality. Wildcards and unsupported media types stand for defaultFormat,
+// so that a browser asking for text/html first, and for application/xml with a lower quality, gets the default format.
+func acceptedFormat(accept string) string {
+	best := 0.0
+	format := defaultFormat
+	for _, entry := range strings.Split(accept, ",") {
+		mediaType, params, _ := strings.Cut(entry, ";")
+		quality, ok := acceptQuality(params)
+		if !ok || quality <= best {
+			continue
+		}
+
+		best, format = quality, defaultFormat
+		if name, supported := acceptedMediaTypes[strings.ToLower(strings.TrimSpace(mediaType))]; supported {
+			format = name
+		}
+	}
+
+	return format
+}
+
+// acceptQuality returns the q parameter of the parameters of an Accept entry, 1 when absent.
+// Malformed and zero qualities, which refuse the media type, are reported as not ok.
+func acceptQuality(params string) (float64, bool) {
+	for _, param := range strings.Split(params, ";") {
+		key, value, _ := strings.Cut(param, "=")
+		if strings.TrimSpace(key) != "q" {
+			continue
+		}
+		q, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil || q <= 0 || q > 1 {
+			return 0, false
+		}
+		return q, true
+	}
+
+	return 1, true
+}
+
+// formatNames returns the sorted list of format names
+func formatNames() string {
+	names := make([]string, 0, len(termFormats))
+	for name := range termFormats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return strings.Join(names, ", ")
+}
+
+// joinedJSONEncoder writes the terms joined with commas in a {"result": "..."} object
+type joinedJSONEncoder struct {
+	w       io.Writer
+	started bool
+}
+
+func (e *joinedJSONEncoder) Encode(term domain.Term) error {
+	prefix := ","
+	if !e.started {
+		prefix = `{"result":"`
+		e.started = true
+	}
+
+	value, err := json.Marshal(term.Value)
+	if err != nil {
+		return err
+	}
+
+	// Strip the quotes, the value is written inside the result string
+	_, err = io.WriteString(e.w, prefix+string(value[1:len(value)-1]))
+	return err
+}
+
+func (e *joinedJSONEncoder) Close() error {
+	if !e.started {
+		_, err := io.WriteString(e.w, `{"result":""}`)
+		return err
+	}
+
+	_, err := io.WriteString(e.w, `"}`)
+	return err
+}
+
+// jsonArrayEncoder writes a JSON array with one element per term
+type jsonArrayEncoder struct {
+	w       io.Writer
+	value   func(term domain.Term) any
+	started bool
+}
+
+func (e *jsonArrayEncoder) Encode(term domain.Term) error {
+	prefix := ","
+	if !e.started {
+		prefix = "["
+		e.started = true
+	}
+
+	b, err := json.Marshal(e.value(term))
+	if err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(e.w, prefix+string(b))
+	return err
+}
+
+func (e *jsonArrayEncoder) Close() error {
+	if !e.started {
+		_, err := io.WriteString(e.w, "[]")
+		return err
+	}
+
+	_, err := io.WriteString(e.w, "]")
+	return err
+}
+
+// ndjsonEncoder writes one JSON object per line
+type ndjsonEncoder struct {
+	enc *json.Encoder
+}
+
+func (e *ndjsonEncoder) Encode(term domain.Term) error {
+	return e.enc.Encode(term)
+}
+
+func (e *ndjsonEncoder) Close() error {
+	return nil
+}
+
+// textEncoder writes one term value per line
+type textEncoder struct {
+	w io.Writer
+}
+
+func (e *textEncoder) Encode(term domain.Term) error {
+	_, err := io.WriteString(e.w, term.Value+"\n")
+	return err
+}
+
+func (e *textEncoder) Close() error {
+	return nil
+}
+
+// csvEncoder writes a RFC 4180 CSV with a n,value,kind header
+type csvEncoder struct {
+	w       *csv.Writer
+	started bool
+}
+
+func newCSVEncoder(w io.Writer) termEncoder {
+	cw := csv.NewWriter(w)
+	cw.UseCRLF = true
+
+	return &csvEncoder{w: cw}
+}
+
+func (e *csvEncoder) start() error {
+	e.started = true
+	return e.w.Write([]string{"n", "value", "kind"})
+}
+
+func (e *csvEncoder) Encode(term domain.Term) error {
+	if !e.started {
+		if err := e.start(); err != nil {
+			return err
+		}
+	}
+
+	return e.w.Write([]string{strconv.Itoa(term.N), term.Value, term.Kind})
+}
+
+func (e *csvEncoder) Close() error {
+	if !e.started {
+		if err := e.start(); err != nil {
+			return err
+		}
+	}
+
+	e.w.Flush()
+	return e.w.Error()
+}
+
+// xmlTerm is the XML representation of a term
+type xmlTerm struct {
+	XMLName xml.Name `xml:"term"`
+	N       int      `xml:"n,attr"`
+	Kind    string   `xml:"kind,attr"`
+	Value   string   `xml:",chardata"`
+}
+
+// xmlEncoder writes a <fizzbuzz> document with one <term> element per term
+type xmlEncoder struct {
+	w       io.Writer
+	enc     *xml.Encoder
+	started bool
+}
+
+func (e *xmlEncoder) start() error {
+	e.started = true
+	_, err := io.WriteString(e.w, xml.Header+"<fizzbuzz>")
+	return err
+}
+
+func (e *xmlEncoder) Encode(term domain.Term) error {
+	if !e.started {
+		if err := e.start(); err != nil {
+			return err
+		}
+	}
+
+	if err := e.enc.Encode(xmlTerm{N: term.N, Kind: term.Kind, Value: term.Value}); err != nil {
+		return err
+	}
+
+	return e.enc.Flush()
+}
+
+func (e *xmlEncoder) Close() error {
+	if !e.started {
+		if err := e.start(); err != nil {
+			return err
+		}
+	}
+
+	_, err := io.WriteString(e.w, "</fizzbuzz>")
+	return err
+}
@@ -0,0 +1,46 @@
+package api
+
+import (
+	"lbc/fizzbuzz/domain"
+	"lbc/fizzbuzz/service"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+type healthController struct {
+	healthService service.HealthService
+	logger        *zap.Logger
+}
+
+func SetupHealthController(
+	logger *zap.Logger,
+	router gin.IRouter,
+	healthService service.HealthService) {
+	c := healthController{
+		logger:        logger,
+		healthService: healthService,
+	}
+
+	root := router.Group("/")
+	GET(root, "/healthz", c.getLivenessEndpoint)
+	GET(root, "/readyz", c.getReadinessEndpoint)
+}
+
+// getLivenessEndpoint answers as long as the server handles requests, without checking its dependencies
+func (c *healthController) getLivenessEndpoint(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, domain.Health{Status: domain.HealthStatusOK})
+}
+
+// getReadinessEndpoint checks the dependencies, answering 503 Service Unavailable when one of them is failing
+func (c *healthController) getReadinessEndpoint(ctx *gin.Context) {
+	health := c.healthService.Ready(ctx.Request.Context())
+	if !health.OK() {
+		c.logger.Warn("Not ready", zap.Any("checks", health.Checks))
+		ctx.JSON(http.StatusServiceUnavailable, health)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, health)
+}
@@ -0,0 +1,93 @@
+package api_test
+
+import (
+	"context"
+	"lbc/fizzbuzz/api"
+	"lbc/fizzbuzz/service"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mwm-io/gapi/errors"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestHealthEndpoints(t *testing.T) {
+	var databaseErr errors.Error
+	healthService := service.NewHealthService(map[string]service.HealthCheck{
+		"postgres": func(ctx context.Context) errors.Error { return databaseErr },
+	}, time.Second)
+
+	router := gin.Default()
+	api.SetupHealthController(zap.NewExample(), router, healthService)
+
+	tests := []struct {
+		name         string
+		url          string
+		databaseErr  errors.Error
+		shutdown     bool
+		expectedCode int
+		expectedBody []string
+	}{
+		{
+			name:         "Live",
+			url:          "/healthz",
+			expectedCode: http.StatusOK,
+			expectedBody: []string{`{"status":"ok"}`},
+		},
+		{
+			name:         "Ready",
+			url:          "/readyz",
+			expectedCode: http.StatusOK,
+			expectedBody: []string{`"status":"ok"`, `"postgres":{"status":"ok","latency_ms":`},
+		},
+		{
+			name:         "Database Unavailable",
+			url:          "/readyz/",
+			databaseErr:  errors.ServiceUnavailable("database_unavailable", "connection refused"),
+			expectedCode: http.StatusServiceUnavailable,
+			expectedBody: []string{`"status":"failing"`, `"error":"connection refused"`, `"shutdown":{"status":"ok"`},
+		},
+		{
+			name:         "Live While Database Unavailable",
+			url:          "/healthz",
+			databaseErr:  errors.ServiceUnavailable("database_unavailable", "connection refused"),
+			expectedCode: http.StatusOK,
+			expectedBody: []string{`{"status":"ok"}`},
+		},
+		{
+			name:         "Shutting Down",
+			url:          "/readyz",
+			shutdown:     true,
+			expectedCode: http.StatusServiceUnavailable,
+			expectedBody: []string{`"shutdown":{"status":"failing","latency_ms":0,"error":"the server is shutting down"}`},
+		},
+		{
+			name:         "Live While Shutting Down",
+			url:          "/healthz",
+			expectedCode: http.StatusOK,
+			expectedBody: []string{`{"status":"ok"}`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			databaseErr = tt.databaseErr
+			if tt.shutdown {
+				healthService.Shutdown()
+			}
+
+			req := httptest.NewRequest(http.MethodGet, tt.url, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedCode, w.Code)
+			for _, expected := range tt.expectedBody {
+				assert.Contains(t, w.Body.String(), expected)
+			}
+		})
+	}
+}
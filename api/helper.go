@@ -8,12 +8,21 @@ import (
 
 // GET registers a route that works with or without a trailing slash.
 func GET(g *gin.RouterGroup, route string, handlers ...gin.HandlerFunc) {
-	var otherRoute string
+	g.GET(route, handlers...)
+	g.GET(otherRoute(route), handlers...)
+}
+
+// POST registers a route that works with or without a trailing slash.
+func POST(g *gin.RouterGroup, route string, handlers ...gin.HandlerFunc) {
+	g.POST(route, handlers...)
+	g.POST(otherRoute(route), handlers...)
+}
+
+// otherRoute returns route with a trailing slash added or removed.
+func otherRoute(route string) string {
 	if route[len(route)-1] == '/' {
-		otherRoute = strings.TrimRight(route, "/")
-	} else {
-		otherRoute = route + "/"
+		return strings.TrimRight(route, "/")
 	}
-	g.GET(route, handlers...)
-	g.GET(otherRoute, handlers...)
+
+	return route + "/"
 }
@@ -0,0 +1,102 @@
+package api
+
+import (
+	"lbc/fizzbuzz/domain"
+	"lbc/fizzbuzz/internal"
+	"lbc/fizzbuzz/service"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+type jobController struct {
+	jobService service.JobService
+	logger     *zap.Logger
+	limits     internal.LimitsConfig
+}
+
+// JobResponse adds the progress of the job, between 0 and 1
+type JobResponse struct {
+	domain.FizzbuzzJob
+	Progress float64 `json:"progress"`
+}
+
+func SetupJobController(
+	logger *zap.Logger,
+	router gin.IRouter,
+	jobService service.JobService,
+	limits internal.LimitsConfig) {
+	c := jobController{
+		logger:     logger,
+		jobService: jobService,
+		limits:     limits,
+	}
+
+	root := router.Group("/api/v1/fizzbuzz/jobs")
+	POST(root, "/", c.createJobEndpoint)
+	GET(root, "/:id", c.getJobEndpoint)
+	GET(root, "/:id/result", c.getJobResultEndpoint)
+	POST(root, "/:id/cancel", c.cancelJobEndpoint)
+}
+
+// createJobEndpoint queues the generation of the sequence given as a JSON body
+func (c *jobController) createJobEndpoint(ctx *gin.Context) {
+	fbInput, err := GetBodyParams(ctx, c.limits)
+	if err != nil {
+		c.logger.Error("Failed to parse request body", zap.Error(err))
+		ctx.JSON(err.StatusCode(), gin.H{"error": err})
+		return
+	}
+
+	job, err := c.jobService.Submit(ctx.Request.Context(), fbInput)
+	if err != nil {
+		c.logger.Error("Failed to submit job", zap.Error(err))
+		ctx.JSON(err.StatusCode(), gin.H{"error": err})
+		return
+	}
+
+	ctx.Header("Location", "/api/v1/fizzbuzz/jobs/"+job.ID)
+	ctx.JSON(http.StatusAccepted, newJobResponse(job))
+}
+
+// getJobEndpoint returns the status and progress of a job
+func (c *jobController) getJobEndpoint(ctx *gin.Context) {
+	job, err := c.jobService.Get(ctx.Request.Context(), ctx.Param("id"))
+	if err != nil {
+		c.logger.Error("Failed to get job", zap.Error(err))
+		ctx.JSON(err.StatusCode(), gin.H{"error": err})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, newJobResponse(job))
+}
+
+// getJobResultEndpoint downloads the result of a succeeded job, one term per line
+func (c *jobController) getJobResultEndpoint(ctx *gin.Context) {
+	id := ctx.Param("id")
+	path, err := c.jobService.ResultPath(ctx.Request.Context(), id)
+	if err != nil {
+		c.logger.Error("Failed to get job result", zap.Error(err))
+		ctx.JSON(err.StatusCode(), gin.H{"error": err})
+		return
+	}
+
+	ctx.FileAttachment(path, "fizzbuzz-"+id+".txt")
+}
+
+// cancelJobEndpoint cancels a queued or running job
+func (c *jobController) cancelJobEndpoint(ctx *gin.Context) {
+	job, err := c.jobService.Cancel(ctx.Request.Context(), ctx.Param("id"))
+	if err != nil {
+		c.logger.Error("Failed to cancel job", zap.Error(err))
+		ctx.JSON(err.StatusCode(), gin.H{"error": err})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, newJobResponse(job))
+}
+
+func newJobResponse(job domain.FizzbuzzJob) JobResponse {
+	return JobResponse{FizzbuzzJob: job, Progress: job.Progress()}
+}
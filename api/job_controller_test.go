@@ -0,0 +1,70 @@
+package api_test
+
+import (
+	"context"
+	"encoding/json"
+	"lbc/fizzbuzz/api"
+	"lbc/fizzbuzz/domain"
+	"lbc/fizzbuzz/internal"
+	"lbc/fizzbuzz/repository"
+	"lbc/fizzbuzz/service"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// TestJobEndpoints tests the lifecycle of a job through the API
+func TestJobEndpoints(t *testing.T) {
+	router := gin.Default()
+	jobService := service.NewJobService(repository.NewMemoryJobRepository(), repository.NewMemoryFizzBuzzRepository(),
+		internal.JobsConfig{Workers: 1, QueueSize: 10, ResultDir: t.TempDir(), Retention: time.Hour}, zap.NewExample())
+	require.Nil(t, jobService.Start(context.Background()))
+	defer jobService.Close(context.Background())
+	api.SetupJobController(zap.NewExample(), router, jobService, limits)
+
+	do := func(method, url, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, url, strings.NewReader(body))
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		return resp
+	}
+
+	resp := do(http.MethodPost, "/api/v1/fizzbuzz/jobs", `{"int1":0,"int2":5,"limit":15,"str1":"fizz","str2":"buzz"}`)
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	assert.Contains(t, resp.Body.String(), `"message":"int1 must be different than 0","kind":"invalid_input"`)
+
+	resp = do(http.MethodPost, "/api/v1/fizzbuzz/jobs", `{"int1":3,"int2":5,"limit":15,"str1":"fizz","str2":"buzz"}`)
+	require.Equal(t, http.StatusAccepted, resp.Code)
+	var job api.JobResponse
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &job))
+	assert.Equal(t, "/api/v1/fizzbuzz/jobs/"+job.ID, resp.Header().Get("Location"))
+	assert.Equal(t, 15, job.Total)
+
+	require.Eventually(t, func() bool {
+		resp := do(http.MethodGet, "/api/v1/fizzbuzz/jobs/"+job.ID, "")
+		require.Equal(t, http.StatusOK, resp.Code)
+		require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &job))
+		return job.Status == domain.JobStatusSucceeded
+	}, 10*time.Second, 10*time.Millisecond)
+	assert.Equal(t, 1.0, job.Progress)
+
+	resp = do(http.MethodGet, "/api/v1/fizzbuzz/jobs/"+job.ID+"/result", "")
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, "1\n2\nfizz\n4\nbuzz\nfizz\n7\n8\nfizz\nbuzz\n11\nfizz\n13\n14\nfizzbuzz\n", resp.Body.String())
+	assert.Contains(t, resp.Header().Get("Content-Type"), "text/plain")
+
+	resp = do(http.MethodPost, "/api/v1/fizzbuzz/jobs/"+job.ID+"/cancel", "")
+	assert.Equal(t, http.StatusConflict, resp.Code)
+	assert.Contains(t, resp.Body.String(), `"kind":"job_finished"`)
+
+	resp = do(http.MethodGet, "/api/v1/fizzbuzz/jobs/unknown", "")
+	assert.Equal(t, http.StatusNotFound, resp.Code)
+	assert.Contains(t, resp.Body.String(), `"kind":"job_not_found"`)
+}
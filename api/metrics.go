@@ -0,0 +1,49 @@
+package api
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// unmatchedRoute labels the requests matching no route, so that unknown paths do not each create a series
+const unmatchedRoute = "unmatched"
+
+var (
+	httpRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "fizzbuzz_http_requests_total",
+		Help: "Number of HTTP requests handled, by method, route and status.",
+	}, []string{"method", "route", "status"})
+	httpRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "fizzbuzz_http_request_duration_seconds",
+		Help:    "Duration of the HTTP requests, by method, route and status.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+)
+
+// Metrics is a middleware recording the count and duration of the requests.
+// Requests are labelled by the pattern of their route, such as /api/v1/fizzbuzz/jobs/:id, rather than by their path.
+func Metrics() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		start := time.Now()
+		ctx.Next()
+
+		route := ctx.FullPath()
+		if route == "" {
+			route = unmatchedRoute
+		}
+		status := strconv.Itoa(ctx.Writer.Status())
+		httpRequests.WithLabelValues(ctx.Request.Method, route, status).Inc()
+		httpRequestDuration.WithLabelValues(ctx.Request.Method, route, status).Observe(time.Since(start).Seconds())
+	}
+}
+
+// SetupMetricsController exposes the registered metrics in the Prometheus text format
+func SetupMetricsController(router gin.IRouter) {
+	root := router.Group("/")
+	GET(root, "/metrics", gin.WrapH(promhttp.Handler()))
+}
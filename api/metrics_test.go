@@ -0,0 +1,109 @@
+package api_test
+
+import (
+	"lbc/fizzbuzz/api"
+	"lbc/fizzbuzz/repository"
+	"lbc/fizzbuzz/service"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestMetricsEndpoint(t *testing.T) {
+	router := gin.New()
+	router.Use(api.Metrics())
+	fizzBuzzRepository := repository.NewMemoryFizzBuzzRepository()
+	api.SetupFizzBuzzController(zap.NewExample(), router, service.NewFizzBuzzService(fizzBuzzRepository), limits, server)
+	api.SetupMetricsController(router)
+
+	ok := map[string]string{"method": "GET", "route": "/api/v1/fizzbuzz", "status": "200"}
+	badRequest := map[string]string{"method": "GET", "route": "/api/v1/fizzbuzz", "status": "400"}
+	unmatched := map[string]string{"method": "GET", "route": "unmatched", "status": "404"}
+	sequence := map[string]string{"mode": "sequence"}
+	stream := map[string]string{"mode": "stream"}
+
+	// The metrics are global and other tests generate sequences too, so the test checks how much they grow
+	metrics := func() map[string]float64 {
+		return map[string]float64{
+			"ok":        metricValue(t, "fizzbuzz_http_requests_total", ok),
+			"ok count":  metricValue(t, "fizzbuzz_http_request_duration_seconds", ok),
+			"bad":       metricValue(t, "fizzbuzz_http_requests_total", badRequest),
+			"unmatched": metricValue(t, "fizzbuzz_http_requests_total", unmatched),
+			"sequence":  metricValue(t, "fizzbuzz_generated_terms_total", sequence),
+			"stream":    metricValue(t, "fizzbuzz_generated_terms_total", stream),
+			"limits":    metricValue(t, "fizzbuzz_requested_limit", nil),
+		}
+	}
+	before := metrics()
+
+	for _, url := range []string{
+		"/api/v1/fizzbuzz?int1=3&int2=5&limit=15&str1=fizz&str2=buzz",
+		"/api/v1/fizzbuzz?int1=3&int2=5&limit=15&str1=fizz&str2=buzz&stream=text",
+		"/api/v1/fizzbuzz?int1=abc",
+		"/unknown/path",
+	} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, url, nil))
+	}
+
+	after := metrics()
+	growth := make(map[string]float64, len(after))
+	for name, value := range after {
+		growth[name] = value - before[name]
+	}
+	assert.Equal(t, map[string]float64{
+		"ok":        2,
+		"ok count":  2,
+		"bad":       1,
+		"unmatched": 1,
+		"sequence":  15,
+		"stream":    15,
+		"limits":    2,
+	}, growth)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `fizzbuzz_http_requests_total{method="GET",route="/api/v1/fizzbuzz",status="200"}`)
+	assert.Contains(t, w.Body.String(), `fizzbuzz_requested_limit_bucket{le="100"}`)
+}
+
+// metricValue returns the value of the counter, or the number of observations of the histogram, having the given labels
+func metricValue(t *testing.T, name string, labels map[string]string) float64 {
+	families, err := prometheus.DefaultGatherer.Gather()
+	require.NoError(t, err)
+
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			if hasLabels(metric, labels) {
+				if family.GetType() == dto.MetricType_HISTOGRAM {
+					return float64(metric.GetHistogram().GetSampleCount())
+				}
+				return metric.GetCounter().GetValue()
+			}
+		}
+	}
+
+	return 0
+}
+
+// hasLabels reports whether metric has every label of labels
+func hasLabels(metric *dto.Metric, labels map[string]string) bool {
+	matched := 0
+	for _, label := range metric.GetLabel() {
+		if value, ok := labels[label.GetName()]; ok && value == label.GetValue() {
+			matched++
+		}
+	}
+
+	return matched == len(labels)
+}
@@ -0,0 +1,359 @@
+package api
+
+import (
+	"lbc/fizzbuzz/domain"
+	"lbc/fizzbuzz/service"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mwm-io/gapi/errors"
+	"go.uber.org/zap"
+)
+
+const (
+	// defaultStatsTop is the number of requests returned by a stats page when top is not provided
+	defaultStatsTop = 10
+	// defaultTrendingWindow is the window of the trending ranking when none is provided
+	defaultTrendingWindow = time.Hour
+)
+
+type statsController struct {
+	statsService service.StatsService
+	logger       *zap.Logger
+}
+
+// FizzBuzzStatsResponse is a page of the most requested parameters
+type FizzBuzzStatsResponse struct {
+	domain.FizzbuzzRequestPage
+	// Next is the offset of the following page, omitted on the last one
+	Next int `json:"next,omitempty"`
+	// Series is the number of hits per bucket, only when a bucket is requested
+	Series []domain.HitsBucket `json:"series,omitempty"`
+}
+
+// FizzBuzzBreakdownResponse is a page of the hits grouped by some dimensions
+type FizzBuzzBreakdownResponse struct {
+	domain.BreakdownPage
+	// Next is the offset of the following page, omitted on the last one
+	Next int `json:"next,omitempty"`
+}
+
+// FizzBuzzLimitHistogramResponse is the hits grouped by ranges of limits
+type FizzBuzzLimitHistogramResponse struct {
+	Buckets []domain.LimitBucket `json:"buckets"`
+}
+
+// FizzBuzzTrendingResponse lists the requests whose hits grow the most
+type FizzBuzzTrendingResponse struct {
+	Requests []domain.TrendingRequest `json:"requests"`
+}
+
+func SetupStatsController(
+	logger *zap.Logger,
+	router gin.IRouter,
+	statsService service.StatsService) {
+	c := statsController{
+		logger:       logger,
+		statsService: statsService,
+	}
+
+	root := router.Group("/api/v1/fizzbuzz/stats")
+	GET(root, "/", c.getFizzBuzzStatsEndpoint)
+	GET(root, "/breakdown", c.getFizzBuzzBreakdownEndpoint)
+	GET(root, "/limits", c.getFizzBuzzLimitHistogramEndpoint)
+	GET(root, "/trending", c.getTrendingEndpoint)
+}
+
+// getFizzBuzzStatsEndpoint handles the FizzBuzz stats request
+func (c *statsController) getFizzBuzzStatsEndpoint(ctx *gin.Context) {
+	if !hasStatsQueryParams(ctx) {
+		c.getMostHits(ctx)
+		return
+	}
+
+	query, err := GetStatsQueryParams(ctx)
+	if err != nil {
+		c.logger.Error("Failed to parse query parameters", zap.Error(err))
+		ctx.JSON(err.StatusCode(), gin.H{"error": err})
+		return
+	}
+
+	page, err := c.statsService.ListMostHits(ctx.Request.Context(), query)
+	if err != nil {
+		c.logger.Error("Failed to list most hits FizzBuzzRequests", zap.Error(err))
+		ctx.JSON(err.StatusCode(), gin.H{"error": err})
+		return
+	}
+
+	response := FizzBuzzStatsResponse{FizzbuzzRequestPage: page}
+	if end := query.Offset + len(page.Requests); end < page.Total {
+		response.Next = end
+	}
+
+	if query.Bucket > 0 {
+		response.Series, err = c.statsService.GetHitsSeries(ctx.Request.Context(), query)
+		if err != nil {
+			c.logger.Error("Failed to get hits series", zap.Error(err))
+			ctx.JSON(err.StatusCode(), gin.H{"error": err})
+			return
+		}
+	}
+
+	ctx.JSON(http.StatusOK, response)
+}
+
+// getMostHits returns the single most requested parameters, or no content when nothing has been recorded
+func (c *statsController) getMostHits(ctx *gin.Context) {
+	fbRequest, err := c.statsService.GetMostHits(ctx.Request.Context())
+	if err != nil && err.StatusCode() == http.StatusNotFound {
+		ctx.Status(http.StatusNoContent)
+		return
+	}
+	if err != nil {
+		c.logger.Error("Failed to get most hits FizzBuzzRequest", zap.Error(err))
+		ctx.JSON(err.StatusCode(), gin.H{"error": err})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, fbRequest)
+}
+
+// getFizzBuzzBreakdownEndpoint returns the hits grouped by the dimensions listed in the by parameter
+func (c *statsController) getFizzBuzzBreakdownEndpoint(ctx *gin.Context) {
+	query, err := GetBreakdownQueryParams(ctx)
+	if err != nil {
+		c.logger.Error("Failed to parse query parameters", zap.Error(err))
+		ctx.JSON(err.StatusCode(), gin.H{"error": err})
+		return
+	}
+
+	page, err := c.statsService.GetBreakdown(ctx.Request.Context(), query)
+	if err != nil {
+		c.logger.Error("Failed to get stats breakdown", zap.Error(err))
+		ctx.JSON(err.StatusCode(), gin.H{"error": err})
+		return
+	}
+
+	response := FizzBuzzBreakdownResponse{BreakdownPage: page}
+	if end := query.Offset + len(page.Groups); end < page.Total {
+		response.Next = end
+	}
+
+	ctx.JSON(http.StatusOK, response)
+}
+
+// getFizzBuzzLimitHistogramEndpoint returns the hits grouped by ranges of limits
+func (c *statsController) getFizzBuzzLimitHistogramEndpoint(ctx *gin.Context) {
+	query, err := GetLimitHistogramQueryParams(ctx)
+	if err != nil {
+		c.logger.Error("Failed to parse query parameters", zap.Error(err))
+		ctx.JSON(err.StatusCode(), gin.H{"error": err})
+		return
+	}
+
+	buckets, err := c.statsService.GetLimitHistogram(ctx.Request.Context(), query)
+	if err != nil {
+		c.logger.Error("Failed to get limit histogram", zap.Error(err))
+		ctx.JSON(err.StatusCode(), gin.H{"error": err})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, FizzBuzzLimitHistogramResponse{Buckets: buckets})
+}
+
+// getTrendingEndpoint ranks the requests by the growth of their hits
+func (c *statsController) getTrendingEndpoint(ctx *gin.Context) {
+	window, top, err := GetTrendingQueryParams(ctx)
+	if err != nil {
+		c.logger.Error("Failed to parse query parameters", zap.Error(err))
+		ctx.JSON(err.StatusCode(), gin.H{"error": err})
+		return
+	}
+
+	requests, err := c.statsService.GetTrending(ctx.Request.Context(), window, top)
+	if err != nil {
+		c.logger.Error("Failed to get trending FizzBuzzRequests", zap.Error(err))
+		ctx.JSON(err.StatusCode(), gin.H{"error": err})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, FizzBuzzTrendingResponse{Requests: requests})
+}
+
+// statsQueryParams are the parameters selecting a page of the stats
+var statsQueryParams = []string{"top", "offset", "sort", "order", "since", "until", "bucket"}
+
+// hasStatsQueryParams reports whether a page of the stats is requested, rather than the single most requested parameters
+func hasStatsQueryParams(ctx *gin.Context) bool {
+	for _, name := range statsQueryParams {
+		if _, ok := ctx.GetQuery(name); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// GetStatsQueryParams parses the top, offset, sort, order, since, until and bucket query parameters
+func GetStatsQueryParams(ctx *gin.Context) (domain.StatsQuery, errors.Error) {
+	query := domain.StatsQuery{Sort: domain.StatsSortHits}
+
+	var err errors.Error
+	query.Top, query.Offset, err = parsePageParams(ctx)
+	if err != nil {
+		return query, err
+	}
+
+	if sort := ctx.Query("sort"); sort != "" {
+		query.Sort = sort
+	}
+
+	switch ctx.Query("order") {
+	case "", "desc":
+	case "asc":
+		query.Ascending = true
+	default:
+		return query, errors.BadRequest("failed_to_parse_order", "order must be one of asc, desc")
+	}
+
+	query.Since, query.Until, err = parseWindowParams(ctx)
+	if err != nil {
+		return query, err
+	}
+
+	if bucket := ctx.Query("bucket"); bucket != "" {
+		d, parseErr := time.ParseDuration(bucket)
+		if parseErr != nil {
+			return query, errors.BadRequest("failed_to_parse_bucket", "failed to parse bucket")
+		}
+		query.Bucket = d
+	}
+
+	if err := query.Validate(); err != nil {
+		return query, errors.Wrap(err).WithKind("invalid_input")
+	}
+
+	return query, nil
+}
+
+// parsePageParams parses the optional top and offset query parameters
+func parsePageParams(ctx *gin.Context) (top, offset int, err errors.Error) {
+	top = defaultStatsTop
+	if ctx.Query("top") != "" {
+		top, err = parseIntParam(ctx, "top", false)
+		if err != nil {
+			return 0, 0, err
+		}
+	}
+
+	offset, err = parseIntParam(ctx, "offset", true)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return top, offset, nil
+}
+
+// parseWindowParams parses the optional since and until query parameters
+func parseWindowParams(ctx *gin.Context) (since, until time.Time, err errors.Error) {
+	now := time.Now()
+	since, err = parseTimeParam(ctx, "since", now)
+	if err != nil {
+		return since, until, err
+	}
+
+	until, err = parseTimeParam(ctx, "until", now)
+	if err != nil {
+		return since, until, err
+	}
+
+	return since, until, nil
+}
+
+// parseTimeParam parses an optional RFC 3339 time, or a duration relative to now such as 24h
+func parseTimeParam(ctx *gin.Context, name string, now time.Time) (time.Time, errors.Error) {
+	value := ctx.Query(name)
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	if d, err := time.ParseDuration(value); err == nil && d > 0 {
+		return now.Add(-d), nil
+	}
+
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, errors.BadRequest("failed_to_parse_"+name, "failed to parse %s", name)
+	}
+
+	return t, nil
+}
+
+// GetBreakdownQueryParams parses the by, top, offset, since and until query parameters.
+// Dimensions can be given as repeated by parameters or as a comma separated list.
+func GetBreakdownQueryParams(ctx *gin.Context) (domain.BreakdownQuery, errors.Error) {
+	var query domain.BreakdownQuery
+	for _, by := range ctx.QueryArray("by") {
+		query.Dimensions = append(query.Dimensions, strings.Split(by, ",")...)
+	}
+
+	var err errors.Error
+	query.Top, query.Offset, err = parsePageParams(ctx)
+	if err != nil {
+		return query, err
+	}
+
+	query.Since, query.Until, err = parseWindowParams(ctx)
+	if err != nil {
+		return query, err
+	}
+
+	if err := query.Validate(); err != nil {
+		return query, errors.Wrap(err).WithKind("invalid_input")
+	}
+
+	return query, nil
+}
+
+// GetLimitHistogramQueryParams parses the width, since and until query parameters
+func GetLimitHistogramQueryParams(ctx *gin.Context) (domain.LimitHistogramQuery, errors.Error) {
+	var query domain.LimitHistogramQuery
+
+	var err errors.Error
+	query.Width, err = parseIntParam(ctx, "width", true)
+	if err != nil {
+		return query, err
+	}
+
+	query.Since, query.Until, err = parseWindowParams(ctx)
+	if err != nil {
+		return query, err
+	}
+
+	if err := query.Validate(); err != nil {
+		return query, errors.Wrap(err).WithKind("invalid_input")
+	}
+
+	return query, nil
+}
+
+// GetTrendingQueryParams parses the optional window and top query parameters
+func GetTrendingQueryParams(ctx *gin.Context) (time.Duration, int, errors.Error) {
+	window := defaultTrendingWindow
+	if value := ctx.Query("window"); value != "" {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return 0, 0, errors.BadRequest("failed_to_parse_window", "failed to parse window")
+		}
+		window = d
+	}
+
+	top, _, err := parsePageParams(ctx)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return window, top, nil
+}
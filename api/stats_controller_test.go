@@ -0,0 +1,291 @@
+package api_test
+
+import (
+	"context"
+	"lbc/fizzbuzz/api"
+	"lbc/fizzbuzz/domain"
+	"lbc/fizzbuzz/internal"
+	"lbc/fizzbuzz/repository"
+	"lbc/fizzbuzz/service"
+	"lbc/fizzbuzz/testdata/utils"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestGetFizzBuzzStatsEndpoint(t *testing.T) {
+	router := gin.Default()
+	db := internal.Clients.PostgreSQL()
+	fizzBuzzRepository := repository.NewFizzBuzzRepository(db, zap.NewExample())
+	api.SetupStatsController(zap.NewExample(), router, service.NewStatsService(fizzBuzzRepository))
+
+	err := utils.LoadFixtures(db)
+	assert.Nil(t, err)
+
+	fizzBuzz := `{"int1":3,"int2":5,"limit":100,"str1":"fizz","str2":"buzz","hits":42,` +
+		`"first_seen":"2024-01-01T10:00:00Z","last_seen":"2024-01-01T10:01:00Z"}`
+	fizzBazz := `{"int1":3,"int2":7,"limit":200,"str1":"fizz","str2":"bazz","hits":30,` +
+		`"first_seen":"2024-01-01T09:00:00Z","last_seen":"2024-01-01T09:00:00Z"}`
+	fooBar := `{"int1":2,"int2":4,"limit":50,"str1":"foo","str2":"bar","hits":30,` +
+		`"first_seen":"2024-01-01T11:00:00Z","last_seen":"2024-01-01T11:00:00Z"}`
+	johnDoe := `{"int1":5,"int2":8,"limit":50,"str1":"john","str2":"doe","hits":10,` +
+		`"first_seen":"2024-01-01T10:00:00Z","last_seen":"2024-01-01T10:00:00Z"}`
+
+	tests := []struct {
+		name         string
+		url          string
+		expectedCode int
+		expectedBody string
+	}{
+		{
+			name:         "Successful Stats Fetch",
+			url:          "/api/v1/fizzbuzz/stats",
+			expectedCode: http.StatusOK,
+			expectedBody: `"int1":3,"int2":5,"limit":100,"str1":"fizz","str2":"buzz","hits":42`,
+		},
+		{
+			name:         "Top with tie on hits",
+			url:          "/api/v1/fizzbuzz/stats?top=3",
+			expectedCode: http.StatusOK,
+			expectedBody: `{"requests":[` + fizzBuzz + `,` + fooBar + `,` + fizzBazz + `],"total":4,"next":3}`,
+		},
+		{
+			name:         "Last page",
+			url:          "/api/v1/fizzbuzz/stats?top=3&offset=3",
+			expectedCode: http.StatusOK,
+			expectedBody: `{"requests":[` + johnDoe + `],"total":4}`,
+		},
+		{
+			name:         "Offset past the end",
+			url:          "/api/v1/fizzbuzz/stats?offset=10",
+			expectedCode: http.StatusOK,
+			expectedBody: `{"requests":[],"total":4}`,
+		},
+		{
+			name:         "Sorted by ascending limit",
+			url:          "/api/v1/fizzbuzz/stats?sort=limit&order=asc&top=2",
+			expectedCode: http.StatusOK,
+			expectedBody: `{"requests":[` + fooBar + `,` + johnDoe + `],"total":4,"next":2}`,
+		},
+		{
+			name:         "Time window",
+			url:          "/api/v1/fizzbuzz/stats?since=2024-01-01T10:00:00Z&until=2024-01-01T11:00:00Z",
+			expectedCode: http.StatusOK,
+			expectedBody: `{"requests":[` + fizzBuzz + `,` + johnDoe + `],"total":2}`,
+		},
+		{
+			name:         "Sorted by least recently seen",
+			url:          "/api/v1/fizzbuzz/stats?sort=last_seen&order=asc&top=2",
+			expectedCode: http.StatusOK,
+			expectedBody: `{"requests":[` + fizzBazz + `,` + johnDoe + `],"total":4,"next":2}`,
+		},
+		{
+			name:         "Time series",
+			url:          "/api/v1/fizzbuzz/stats?since=2024-01-01T09:00:00Z&until=2024-01-01T12:00:00Z&bucket=1h&top=1",
+			expectedCode: http.StatusOK,
+			expectedBody: `{"requests":[` + fizzBuzz + `],"total":4,"next":1,` +
+				`"series":[{"start":"2024-01-01T09:00:00Z","hits":30},{"start":"2024-01-01T10:00:00Z","hits":52},` +
+				`{"start":"2024-01-01T11:00:00Z","hits":30}]}`,
+		},
+		{
+			name:         "Invalid top",
+			url:          "/api/v1/fizzbuzz/stats?top=0",
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `"message":"top must be between 1 and 100","kind":"invalid_input"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.url, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedCode, w.Code)
+			assert.Contains(t, w.Body.String(), tt.expectedBody)
+		})
+	}
+}
+
+func TestGetFizzBuzzStatsEndpointEmpty(t *testing.T) {
+	router := gin.Default()
+	db := internal.Clients.PostgreSQL()
+	fizzBuzzRepository := repository.NewFizzBuzzRepository(db, zap.NewExample())
+	api.SetupStatsController(zap.NewExample(), router, service.NewStatsService(fizzBuzzRepository))
+
+	err := utils.ResetDatabase(db)
+	assert.Nil(t, err)
+
+	tests := []struct {
+		name         string
+		url          string
+		expectedCode int
+		expectedBody string
+	}{
+		{
+			name:         "Most hits",
+			url:          "/api/v1/fizzbuzz/stats",
+			expectedCode: http.StatusNoContent,
+			expectedBody: "",
+		},
+		{
+			name:         "Top",
+			url:          "/api/v1/fizzbuzz/stats?top=5",
+			expectedCode: http.StatusOK,
+			expectedBody: `{"requests":[],"total":0}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.url, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedCode, w.Code)
+			assert.Equal(t, tt.expectedBody, w.Body.String())
+		})
+	}
+}
+
+func TestGetFizzBuzzStatsBreakdownEndpoints(t *testing.T) {
+	router := gin.Default()
+	db := internal.Clients.PostgreSQL()
+	fizzBuzzRepository := repository.NewFizzBuzzRepository(db, zap.NewExample())
+	api.SetupStatsController(zap.NewExample(), router, service.NewStatsService(fizzBuzzRepository))
+
+	err := utils.LoadFixtures(db)
+	assert.Nil(t, err)
+
+	tests := []struct {
+		name         string
+		url          string
+		expectedCode int
+		expectedBody string
+	}{
+		{
+			name:         "By str1",
+			url:          "/api/v1/fizzbuzz/stats/breakdown?by=str1",
+			expectedCode: http.StatusOK,
+			expectedBody: `{"groups":[{"values":{"str1":"fizz"},"hits":72,"requests":2},` +
+				`{"values":{"str1":"foo"},"hits":30,"requests":1},` +
+				`{"values":{"str1":"john"},"hits":10,"requests":1}],"total":3}`,
+		},
+		{
+			name:         "By word pair",
+			url:          "/api/v1/fizzbuzz/stats/breakdown?by=str1,str2&top=2",
+			expectedCode: http.StatusOK,
+			expectedBody: `{"groups":[{"values":{"str1":"fizz","str2":"buzz"},"hits":42,"requests":1},` +
+				`{"values":{"str1":"fizz","str2":"bazz"},"hits":30,"requests":1}],"total":4,"next":2}`,
+		},
+		{
+			name:         "By limit",
+			url:          "/api/v1/fizzbuzz/stats/breakdown?by=limit",
+			expectedCode: http.StatusOK,
+			expectedBody: `{"groups":[{"values":{"limit":100},"hits":42,"requests":1},` +
+				`{"values":{"limit":50},"hits":40,"requests":2},` +
+				`{"values":{"limit":200},"hits":30,"requests":1}],"total":3}`,
+		},
+		{
+			name:         "By str1 within a time window",
+			url:          "/api/v1/fizzbuzz/stats/breakdown?by=str1&since=2024-01-01T10:00:00Z&until=2024-01-01T11:00:00Z",
+			expectedCode: http.StatusOK,
+			expectedBody: `{"groups":[{"values":{"str1":"fizz"},"hits":42,"requests":1},` +
+				`{"values":{"str1":"john"},"hits":10,"requests":1}],"total":2}`,
+		},
+		{
+			name:         "Unknown dimension",
+			url:          "/api/v1/fizzbuzz/stats/breakdown?by=str3",
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `"message":"by must be one of int1, int2, limit, str1, str2, rules","kind":"invalid_input"`,
+		},
+		{
+			name:         "Limits by power of ten",
+			url:          "/api/v1/fizzbuzz/stats/limits",
+			expectedCode: http.StatusOK,
+			expectedBody: `{"buckets":[{"min":10,"max":99,"hits":40,"requests":2},{"min":100,"max":999,"hits":72,"requests":2}]}`,
+		},
+		{
+			name:         "Limits by width",
+			url:          "/api/v1/fizzbuzz/stats/limits?width=100",
+			expectedCode: http.StatusOK,
+			expectedBody: `{"buckets":[{"min":1,"max":100,"hits":82,"requests":3},{"min":101,"max":200,"hits":30,"requests":1}]}`,
+		},
+		{
+			name:         "Limits within a time window",
+			url:          "/api/v1/fizzbuzz/stats/limits?width=100&since=2024-01-01T10:00:00Z",
+			expectedCode: http.StatusOK,
+			expectedBody: `{"buckets":[{"min":1,"max":100,"hits":82,"requests":3}]}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.url, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedCode, w.Code)
+			assert.Contains(t, w.Body.String(), tt.expectedBody)
+		})
+	}
+}
+
+func TestGetTrendingEndpoint(t *testing.T) {
+	router := gin.Default()
+	db := internal.Clients.PostgreSQL()
+	fizzBuzzRepository := repository.NewFizzBuzzRepository(db, zap.NewExample())
+	api.SetupStatsController(zap.NewExample(), router, service.NewStatsService(fizzBuzzRepository))
+
+	require.Nil(t, utils.ResetDatabase(db))
+	fizzBuzz := domain.FizzBuzzInput{Int1: 3, Int2: 5, Limit: 100, Str1: "fizz", Str2: "buzz"}
+	require.Nil(t, fizzBuzzRepository.SaveBatch(context.Background(), []domain.FizzBuzzInput{fizzBuzz, fizzBuzz}))
+
+	tests := []struct {
+		name         string
+		url          string
+		expectedCode int
+		expectedBody string
+	}{
+		{
+			name:         "Default window",
+			url:          "/api/v1/fizzbuzz/stats/trending",
+			expectedCode: http.StatusOK,
+			expectedBody: `{"requests":[{"int1":3,"int2":5,"limit":100,"str1":"fizz","str2":"buzz","hits":2,"previous_hits":0,"growth":2}]}`,
+		},
+		{
+			name:         "Invalid window",
+			url:          "/api/v1/fizzbuzz/stats/trending?window=hourly",
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `"message":"failed to parse window","kind":"failed_to_parse_window"`,
+		},
+		{
+			name:         "Window below resolution",
+			url:          "/api/v1/fizzbuzz/stats/trending?window=90s",
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `"kind":"invalid_input"`,
+		},
+		{
+			name:         "Invalid top",
+			url:          "/api/v1/fizzbuzz/stats/trending?top=abc",
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `"message":"failed to parse top","kind":"failed_to_parse_top"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.url, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedCode, w.Code)
+			assert.Contains(t, w.Body.String(), tt.expectedBody)
+		})
+	}
+}
@@ -0,0 +1,241 @@
+package api_test
+
+import (
+	"lbc/fizzbuzz/api"
+	"lbc/fizzbuzz/domain"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetStatsQueryParams(t *testing.T) {
+	tests := []struct {
+		name         string
+		query        string
+		expected     domain.StatsQuery
+		expectedKind string
+	}{
+		{
+			name:     "Defaults",
+			query:    "top=",
+			expected: domain.StatsQuery{Top: 10, Sort: domain.StatsSortHits},
+		},
+		{
+			name:     "All parameters",
+			query:    "top=5&offset=10&sort=limit&order=asc",
+			expected: domain.StatsQuery{Top: 5, Offset: 10, Sort: domain.StatsSortLimit, Ascending: true},
+		},
+		{
+			name:     "Sort by recency",
+			query:    "sort=last_seen",
+			expected: domain.StatsQuery{Top: 10, Sort: domain.StatsSortLastSeen},
+		},
+		{
+			name:         "Non-integer top",
+			query:        "top=abc",
+			expectedKind: "failed_to_parse_top",
+		},
+		{
+			name:         "Top above maximum",
+			query:        "top=101",
+			expectedKind: "invalid_input",
+		},
+		{
+			name:         "Negative offset",
+			query:        "offset=-1",
+			expectedKind: "invalid_input",
+		},
+		{
+			name:         "Unknown sort",
+			query:        "sort=str1",
+			expectedKind: "invalid_input",
+		},
+		{
+			name:         "Unknown order",
+			query:        "order=up",
+			expectedKind: "failed_to_parse_order",
+		},
+		{
+			name:  "Time window with buckets",
+			query: "since=2024-01-01T10:00:00Z&until=2024-01-01T12:00:00Z&bucket=15m",
+			expected: domain.StatsQuery{
+				Top:    10,
+				Sort:   domain.StatsSortHits,
+				Since:  time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC),
+				Until:  time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC),
+				Bucket: 15 * time.Minute,
+			},
+		},
+		{
+			name:         "Invalid since",
+			query:        "since=yesterday",
+			expectedKind: "failed_to_parse_since",
+		},
+		{
+			name:         "Since after until",
+			query:        "since=2024-01-01T12:00:00Z&until=2024-01-01T10:00:00Z",
+			expectedKind: "invalid_input",
+		},
+		{
+			name:         "Invalid bucket",
+			query:        "since=1h&bucket=hourly",
+			expectedKind: "failed_to_parse_bucket",
+		},
+		{
+			name:         "Bucket below resolution",
+			query:        "since=1h&bucket=30s",
+			expectedKind: "invalid_input",
+		},
+		{
+			name:         "Bucket without since",
+			query:        "bucket=1h",
+			expectedKind: "invalid_input",
+		},
+		{
+			name:         "Too many buckets",
+			query:        "since=2024-01-01T00:00:00Z&until=2024-02-01T00:00:00Z&bucket=1m",
+			expectedKind: "invalid_input",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+			ctx.Request = httptest.NewRequest(http.MethodGet, "/api/v1/fizzbuzz/stats?"+tt.query, nil)
+
+			result, err := api.GetStatsQueryParams(ctx)
+
+			if tt.expectedKind != "" {
+				require.Error(t, err)
+				assert.Equal(t, tt.expectedKind, err.Kind())
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestGetStatsQueryParamsRelativeTime(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+	ctx.Request = httptest.NewRequest(http.MethodGet, "/api/v1/fizzbuzz/stats?since=24h&bucket=1h", nil)
+
+	result, err := api.GetStatsQueryParams(ctx)
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(-24*time.Hour), result.Since, time.Minute)
+	assert.True(t, result.Until.IsZero())
+	assert.Equal(t, time.Hour, result.Bucket)
+}
+
+func TestGetBreakdownQueryParams(t *testing.T) {
+	tests := []struct {
+		name         string
+		query        string
+		expected     domain.BreakdownQuery
+		expectedKind string
+	}{
+		{
+			name:     "Repeated dimensions",
+			query:    "by=str1&by=str2",
+			expected: domain.BreakdownQuery{Dimensions: []string{"str1", "str2"}, Top: 10},
+		},
+		{
+			name:     "Comma separated dimensions",
+			query:    "by=int1,limit&top=5&offset=5",
+			expected: domain.BreakdownQuery{Dimensions: []string{"int1", "limit"}, Top: 5, Offset: 5},
+		},
+		{
+			name:         "Missing dimension",
+			query:        "top=5",
+			expectedKind: "invalid_input",
+		},
+		{
+			name:         "Unknown dimension",
+			query:        "by=hits",
+			expectedKind: "invalid_input",
+		},
+		{
+			name:         "Repeated dimension",
+			query:        "by=str1,str1",
+			expectedKind: "invalid_input",
+		},
+		{
+			name:         "Invalid since",
+			query:        "by=str1&since=yesterday",
+			expectedKind: "failed_to_parse_since",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+			ctx.Request = httptest.NewRequest(http.MethodGet, "/api/v1/fizzbuzz/stats/breakdown?"+tt.query, nil)
+
+			result, err := api.GetBreakdownQueryParams(ctx)
+
+			if tt.expectedKind != "" {
+				require.Error(t, err)
+				assert.Equal(t, tt.expectedKind, err.Kind())
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestGetLimitHistogramQueryParams(t *testing.T) {
+	tests := []struct {
+		name         string
+		query        string
+		expected     domain.LimitHistogramQuery
+		expectedKind string
+	}{
+		{
+			name:     "Powers of ten",
+			query:    "",
+			expected: domain.LimitHistogramQuery{},
+		},
+		{
+			name:     "Width",
+			query:    "width=1000",
+			expected: domain.LimitHistogramQuery{Width: 1000},
+		},
+		{
+			name:         "Negative width",
+			query:        "width=-10",
+			expectedKind: "invalid_input",
+		},
+		{
+			name:         "Non-integer width",
+			query:        "width=wide",
+			expectedKind: "failed_to_parse_width",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+			ctx.Request = httptest.NewRequest(http.MethodGet, "/api/v1/fizzbuzz/stats/limits?"+tt.query, nil)
+
+			result, err := api.GetLimitHistogramQueryParams(ctx)
+
+			if tt.expectedKind != "" {
+				require.Error(t, err)
+				assert.Equal(t, tt.expectedKind, err.Kind())
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, tt.expected, result)
+			}
+		})
+	}
+}
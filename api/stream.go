@@ -0,0 +1,105 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"lbc/fizzbuzz/domain"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mwm-io/gapi/errors"
+	"go.uber.org/zap"
+)
+
+const (
+	// streamBufferSize is the size of the buffer terms are written to before being sent
+	streamBufferSize = 32 * 1024
+	// streamFlushInterval is the number of terms written between two flushes of the response
+	streamFlushInterval = 4096
+)
+
+// isStream reports whether the request asks for its sequence to be streamed, in the format given by the stream parameter
+func isStream(ctx *gin.Context) bool {
+	return ctx.Query("stream") != ""
+}
+
+// writeFizzBuzz encodes the sequence in the given format.
+// When streaming, terms are sent while they are generated using chunked transfer encoding,
+// and errors can only be reported as JSON as long as nothing has been written yet.
+func (c *fizzBuzzController) writeFizzBuzz(ctx *gin.Context, input domain.FizzBuzzInput, format string, stream bool) {
+	termFormat, ok := termFormats[format]
+	if !ok {
+		err := errors.BadRequest("invalid_stream_format", "stream must be one of %s", formatNames())
+		c.logger.Error("Failed to parse query parameters", zap.Error(err))
+		ctx.JSON(err.StatusCode(), gin.H{"error": err})
+		return
+	}
+
+	var buf bytes.Buffer
+	var w io.Writer = &buf
+	var streamWriter *bufio.Writer
+	// The write timeout of the server bounds each chunk of a stream rather than the whole of it,
+	// so that long sequences are not cut off while the client keeps reading
+	writeTimeout := c.server.WriteTimeout
+	responseController := http.NewResponseController(ctx.Writer)
+	if stream {
+		streamWriter = bufio.NewWriterSize(ctx.Writer, streamBufferSize)
+		w = streamWriter
+	}
+
+	encoder := termFormat.newEncoder(w)
+	written := 0
+	err := c.fizzBuzzService.StreamFizzBuzz(ctx.Request.Context(), input, func(term domain.Term) error {
+		if stream && written == 0 {
+			ctx.Header("Content-Type", termFormat.contentType)
+			ctx.Status(http.StatusOK)
+		}
+
+		if err := encoder.Encode(term); err != nil {
+			return err
+		}
+
+		written++
+		if stream && written%streamFlushInterval == 0 {
+			// Not every ResponseWriter supports deadlines, the test recorders in particular
+			_ = responseController.SetWriteDeadline(time.Now().Add(writeTimeout))
+			if err := streamWriter.Flush(); err != nil {
+				return err
+			}
+			ctx.Writer.Flush()
+		}
+
+		return nil
+	})
+	// A sequence whose every term was written is closed even when recording the request failed,
+	// so that a stream already sent still ends well-formed
+	first, last := input.Window()
+	complete := written > 0 && written == last-first+1
+	if complete {
+		if closeErr := encoder.Close(); err == nil {
+			err = errors.Wrap(closeErr)
+		}
+	}
+	if err != nil {
+		c.logger.Error("Failed to write FizzBuzz", zap.Error(err), zap.Int("written", written))
+		if !stream || written == 0 {
+			ctx.JSON(err.StatusCode(), gin.H{"error": err})
+			return
+		}
+		if !complete {
+			return
+		}
+	}
+
+	if !stream {
+		ctx.Data(http.StatusOK, termFormat.contentType, buf.Bytes())
+		return
+	}
+
+	_ = responseController.SetWriteDeadline(time.Now().Add(writeTimeout))
+	if err := streamWriter.Flush(); err != nil {
+		c.logger.Error("Failed to write FizzBuzz", zap.Error(err), zap.Int("written", written))
+	}
+}
@@ -0,0 +1,25 @@
+package api
+
+import (
+	"context"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequestTimeout is a middleware bounding the handling of each request by a deadline on its context,
+// so that the generation and database calls of a request still running after it are abandoned.
+// Streamed sequences are left without deadline, the write timeout bounding each of their chunks instead.
+func RequestTimeout(timeout time.Duration) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if isStream(ctx) {
+			ctx.Next()
+			return
+		}
+
+		requestCtx, cancel := context.WithTimeout(ctx.Request.Context(), timeout)
+		defer cancel()
+		ctx.Request = ctx.Request.WithContext(requestCtx)
+		ctx.Next()
+	}
+}
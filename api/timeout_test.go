@@ -0,0 +1,69 @@
+package api_test
+
+import (
+	"encoding/json"
+	"lbc/fizzbuzz/api"
+	"lbc/fizzbuzz/repository"
+	"lbc/fizzbuzz/service"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestRequestTimeout(t *testing.T) {
+	// The deadline is over before the handler starts, so that every bounded request times out
+	router := gin.New()
+	router.Use(api.RequestTimeout(time.Nanosecond))
+	fizzBuzzRepository := repository.NewMemoryFizzBuzzRepository()
+	api.SetupFizzBuzzController(zap.NewExample(), router, service.NewFizzBuzzService(fizzBuzzRepository), limits, server)
+
+	tests := []struct {
+		name   string
+		url    string
+		status int
+		kind   string
+	}{
+		{
+			name:   "Sequence",
+			url:    "/api/v1/fizzbuzz?int1=3&int2=5&limit=10000&str1=fizz&str2=buzz",
+			status: http.StatusServiceUnavailable,
+			kind:   "request_timeout",
+		},
+		{
+			name:   "Format",
+			url:    "/api/v1/fizzbuzz?int1=3&int2=5&limit=10000&str1=fizz&str2=buzz&format=csv",
+			status: http.StatusServiceUnavailable,
+			kind:   "request_timeout",
+		},
+		{
+			name:   "Stream Is Not Bounded",
+			url:    "/api/v1/fizzbuzz?int1=3&int2=5&limit=10000&str1=fizz&str2=buzz&stream=text",
+			status: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.url, nil))
+			require.Equal(t, tt.status, w.Code, w.Body.String())
+			if tt.kind == "" {
+				return
+			}
+
+			var response struct {
+				Error struct {
+					Kind string `json:"kind"`
+				} `json:"error"`
+			}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			assert.Equal(t, tt.kind, response.Error.Kind)
+		})
+	}
+}
@@ -1,6 +1,24 @@
 package domain
 
-import "github.com/mwm-io/gapi/errors"
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"math"
+	"time"
+	"unicode/utf8"
+
+	"github.com/mwm-io/gapi/errors"
+)
+
+const (
+	// MaxRules is the maximum number of rules accepted in a single rule set
+	MaxRules = 16
+	// MaxWordLength is the maximum number of characters of str1, str2 and the words of the rules, as stored by the database
+	MaxWordLength = 50
+	// MaxLimit is the maximum limit, and the bound of int1 and int2, stored as 32-bit integers by the database
+	MaxLimit = math.MaxInt32
+)
 
 type FizzBuzzInput struct {
 	Int1  int    `json:"int1"  bun:"int1"`
@@ -8,21 +26,147 @@ type FizzBuzzInput struct {
 	Limit int    `json:"limit" bun:"max_limit"`
 	Str1  string `json:"str1"  bun:"str1"`
 	Str2  string `json:"str2"  bun:"str2"`
+	Rules Rules  `json:"rules,omitempty" bun:"rules"`
+	// Start and Count restrict the generation to a window of the sequence, they are not persisted
+	Start int `json:"start,omitempty" bun:"-"`
+	Count int `json:"count,omitempty" bun:"-"`
+}
+
+// Rule replaces every multiple of Divisor with Word
+type Rule struct {
+	Divisor int    `json:"divisor"`
+	Word    string `json:"word"`
+}
+
+// Rules is an ordered list of Rule, persisted as a JSON array
+type Rules []Rule
+
+// Value implements driver.Valuer
+func (r Rules) Value() (driver.Value, error) {
+	if r == nil {
+		r = Rules{}
+	}
+
+	b, err := json.Marshal(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return string(b), nil
+}
+
+// Scan implements sql.Scanner
+func (r *Rules) Scan(src any) error {
+	switch v := src.(type) {
+	case nil:
+		*r = nil
+		return nil
+	case []byte:
+		return json.Unmarshal(v, r)
+	case string:
+		return json.Unmarshal([]byte(v), r)
+	default:
+		return fmt.Errorf("cannot scan %T into Rules", src)
+	}
+}
+
+// RuleSet returns the rules to apply, in declared order.
+// Int1/Str1 and Int2/Str2 form a two-rule set when no explicit Rules are given.
+func (f FizzBuzzInput) RuleSet() Rules {
+	if len(f.Rules) > 0 {
+		return f.Rules
+	}
+
+	return Rules{
+		{Divisor: f.Int1, Word: f.Str1},
+		{Divisor: f.Int2, Word: f.Str2},
+	}
+}
+
+// Window returns the first and last positions to generate, within 1..Limit
+func (f FizzBuzzInput) Window() (first, last int) {
+	first, last = 1, f.Limit
+	if f.Start > 0 {
+		first = f.Start
+	}
+
+	if f.Count > 0 && f.Count <= last-first {
+		last = first + f.Count - 1
+	}
+
+	return first, last
+}
+
+// NextStart returns the start of the window following this one, or 0 when the window reaches the limit
+func (f FizzBuzzInput) NextStart() int {
+	_, last := f.Window()
+	if last >= f.Limit {
+		return 0
+	}
+
+	return last + 1
 }
 
+// PrevStart returns the start of the window preceding this one, or 0 when the window starts at 1
+func (f FizzBuzzInput) PrevStart() int {
+	first, last := f.Window()
+	if first <= 1 {
+		return 0
+	}
+
+	size := f.Count
+	if size == 0 {
+		size = last - first + 1
+	}
+
+	return max(1, first-size)
+}
+
+// Validate validates an input to generate and record, whose limit must fit in the database
 func (f FizzBuzzInput) Validate() error {
-	if f.Int1 == 0 {
-		return errors.BadRequest("invalid_input", "int1 must be different than 0")
+	if err := f.ValidateUnbounded(); err != nil {
+		return err
 	}
 
-	if f.Int2 == 0 {
-		return errors.BadRequest("invalid_input", "int2 must be different than 0")
+	if f.Limit > MaxLimit {
+		return errors.BadRequest("invalid_input", "limit must not be greater than %d", MaxLimit)
+	}
+
+	return nil
+}
+
+// ValidateUnbounded validates an input that is neither generated in full nor recorded, so that its limit is not bounded
+func (f FizzBuzzInput) ValidateUnbounded() error {
+	if err := f.ValidateRules(); err != nil {
+		return err
 	}
 
 	if f.Limit <= 0 {
 		return errors.BadRequest("invalid_input", "limit must be greater than 0")
 	}
 
+	return f.validateWindow()
+}
+
+// ValidateRules validates the rule set only, regardless of the limit and window
+func (f FizzBuzzInput) ValidateRules() error {
+	if len(f.Rules) > 0 {
+		return f.validateRules()
+	}
+
+	return f.validatePair()
+}
+
+// validatePair validates an input given as int1/str1 and int2/str2
+func (f FizzBuzzInput) validatePair() error {
+	if f.Int1 == 0 {
+		return errors.BadRequest("invalid_input", "int1 must be different than 0")
+	}
+
+	if f.Int2 == 0 {
+		return errors.BadRequest("invalid_input", "int2 must be different than 0")
+	}
+
 	if f.Str1 == "" {
 		return errors.BadRequest("invalid_input", "str1 must not be empty")
 	}
@@ -31,6 +175,22 @@ func (f FizzBuzzInput) Validate() error {
 		return errors.BadRequest("invalid_input", "str2 must not be empty")
 	}
 
+	if err := validateInteger("int1", f.Int1); err != nil {
+		return err
+	}
+
+	if err := validateInteger("int2", f.Int2); err != nil {
+		return err
+	}
+
+	if err := validateWord("str1", f.Str1); err != nil {
+		return err
+	}
+
+	if err := validateWord("str2", f.Str2); err != nil {
+		return err
+	}
+
 	if f.Int1 == f.Int2 {
 		return errors.BadRequest("invalid_input", "int1 and int2 must be different")
 	}
@@ -38,7 +198,114 @@ func (f FizzBuzzInput) Validate() error {
 	return nil
 }
 
+// validateRules validates an input given as an explicit list of rules
+func (f FizzBuzzInput) validateRules() error {
+	if f.Int1 != 0 || f.Int2 != 0 || f.Str1 != "" || f.Str2 != "" {
+		return errors.BadRequest("invalid_input", "rules cannot be combined with int1, int2, str1 and str2")
+	}
+
+	if len(f.Rules) > MaxRules {
+		return errors.BadRequest("invalid_input", "at most %d rules are allowed", MaxRules)
+	}
+
+	divisors := make(map[int]struct{}, len(f.Rules))
+	for i, rule := range f.Rules {
+		if rule.Divisor == 0 {
+			return errors.BadRequest("invalid_input", "rules[%d].divisor must be different than 0", i)
+		}
+
+		if rule.Word == "" {
+			return errors.BadRequest("invalid_input", "rules[%d].word must not be empty", i)
+		}
+
+		if err := validateWord(fmt.Sprintf("rules[%d].word", i), rule.Word); err != nil {
+			return err
+		}
+
+		if _, ok := divisors[rule.Divisor]; ok {
+			return errors.BadRequest("invalid_input", "rules[%d].divisor must be different from previous divisors", i)
+		}
+		divisors[rule.Divisor] = struct{}{}
+	}
+
+	return nil
+}
+
+// validateInteger checks that the integer parameter name fits in the 32-bit integers of the database
+func validateInteger(name string, value int) error {
+	if value < -MaxLimit || value > MaxLimit {
+		return errors.BadRequest("invalid_input", "%s must be between %d and %d", name, -MaxLimit, MaxLimit)
+	}
+
+	return nil
+}
+
+// validateWord checks that the word parameter name fits in the columns of the database
+func validateWord(name string, word string) error {
+	if utf8.RuneCountInString(word) > MaxWordLength {
+		return errors.BadRequest("invalid_input", "%s must not be longer than %d characters", name, MaxWordLength)
+	}
+
+	return nil
+}
+
+// validateWindow validates the optional start and count
+func (f FizzBuzzInput) validateWindow() error {
+	if f.Start < 0 {
+		return errors.BadRequest("invalid_input", "start must not be negative")
+	}
+
+	if f.Start > f.Limit {
+		return errors.BadRequest("invalid_input", "start must not be greater than limit")
+	}
+
+	if f.Count < 0 {
+		return errors.BadRequest("invalid_input", "count must not be negative")
+	}
+
+	return nil
+}
+
+// Kinds of Term
+const (
+	// TermKindNumber is a term left as a number
+	TermKindNumber = "number"
+	// TermKindWord is a term replaced by the word of a single rule
+	TermKindWord = "word"
+	// TermKindCombined is a term replaced by the words of several rules
+	TermKindCombined = "combined"
+)
+
+// Term is a single element of a FizzBuzz sequence
+type Term struct {
+	N     int    `json:"n"`
+	Value string `json:"value"`
+	Kind  string `json:"kind"`
+}
+
+// FizzBuzzCounts is the number of terms of each category within a window of the sequence
+type FizzBuzzCounts struct {
+	First int `json:"first"`
+	Last  int `json:"last"`
+	// Plain is the number of terms left as numbers
+	Plain int `json:"plain"`
+	// Combinations lists the terms made of exactly the given words, omitting empty ones
+	Combinations []CombinationCount `json:"combinations"`
+}
+
+// CombinationCount is the number of terms matched by exactly a combination of rules
+type CombinationCount struct {
+	// Rules are the indexes of the matched rules in the rule set
+	Rules []int    `json:"rules"`
+	Words []string `json:"words"`
+	Value string   `json:"value"`
+	Count int      `json:"count"`
+}
+
 type FizzbuzzRequest struct {
 	FizzBuzzInput
 	Hits int `json:"hits" bun:"hits"`
+	// FirstSeen and LastSeen are the times of the first and last hits
+	FirstSeen time.Time `json:"first_seen" bun:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"  bun:"last_seen"`
 }
@@ -0,0 +1,26 @@
+package domain
+
+// Statuses of a Health and of its checks
+const (
+	HealthStatusOK      = "ok"
+	HealthStatusFailing = "failing"
+)
+
+// Health is the outcome of the readiness checks of the dependencies of the server
+type Health struct {
+	Status string                 `json:"status"`
+	Checks map[string]HealthCheck `json:"checks,omitempty"`
+}
+
+// HealthCheck is the outcome of a single dependency check
+type HealthCheck struct {
+	Status string `json:"status"`
+	// LatencyMs is the time the check took, in milliseconds
+	LatencyMs float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// OK reports whether every check succeeded
+func (h Health) OK() bool {
+	return h.Status == HealthStatusOK
+}
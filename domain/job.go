@@ -0,0 +1,40 @@
+package domain
+
+import "time"
+
+// Statuses of a FizzbuzzJob
+const (
+	JobStatusQueued    = "queued"
+	JobStatusRunning   = "running"
+	JobStatusSucceeded = "succeeded"
+	JobStatusFailed    = "failed"
+	JobStatusCancelled = "cancelled"
+)
+
+// FizzbuzzJob is an asynchronous generation of a sequence, written to a result file
+type FizzbuzzJob struct {
+	ID     string        `json:"id"     bun:"id,pk"`
+	Input  FizzBuzzInput `json:"input"  bun:"input"`
+	Status string        `json:"status" bun:"status"`
+	// Generated is the number of terms written so far, out of Total
+	Generated  int        `json:"generated"             bun:"generated"`
+	Total      int        `json:"total"                 bun:"total"`
+	Error      string     `json:"error,omitempty"       bun:"error"`
+	CreatedAt  time.Time  `json:"created_at"            bun:"created_at"`
+	StartedAt  *time.Time `json:"started_at,omitempty"  bun:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty" bun:"finished_at"`
+}
+
+// Finished reports whether the job reached a final status
+func (j FizzbuzzJob) Finished() bool {
+	return j.Status == JobStatusSucceeded || j.Status == JobStatusFailed || j.Status == JobStatusCancelled
+}
+
+// Progress returns the fraction of the terms already generated, between 0 and 1
+func (j FizzbuzzJob) Progress() float64 {
+	if j.Total == 0 {
+		return 0
+	}
+
+	return float64(j.Generated) / float64(j.Total)
+}
@@ -0,0 +1,245 @@
+package domain
+
+import (
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/mwm-io/gapi/errors"
+)
+
+const (
+	// MaxStatsTop is the maximum number of requests returned by a single stats query
+	MaxStatsTop = 100
+	// HitsResolution is the precision hits are recorded with over time
+	HitsResolution = time.Minute
+	// MaxStatsBuckets is the maximum number of buckets of a hits series
+	MaxStatsBuckets = 1000
+)
+
+// Orders of a StatsQuery
+const (
+	// StatsSortHits orders requests by their number of hits
+	StatsSortHits = "hits"
+	// StatsSortLimit orders requests by their limit
+	StatsSortLimit = "limit"
+	// StatsSortFirstSeen orders requests by the time of their first hit
+	StatsSortFirstSeen = "first_seen"
+	// StatsSortLastSeen orders requests by the time of their last hit
+	StatsSortLastSeen = "last_seen"
+)
+
+// StatsSorts lists the accepted StatsQuery.Sort values
+var StatsSorts = []string{StatsSortHits, StatsSortLimit, StatsSortFirstSeen, StatsSortLastSeen}
+
+// StatsQuery selects a page of the recorded requests.
+// Requests equal on Sort are ordered by their parameters, so that pages are deterministic.
+type StatsQuery struct {
+	Top    int
+	Offset int
+	Sort   string
+	// Ascending reverses the default descending order
+	Ascending bool
+	// Since and Until restrict the hits to the [Since, Until) window, a zero time leaves the window open
+	Since time.Time
+	Until time.Time
+	// Bucket is the size of the buckets of the hits series, 0 when no series is requested
+	Bucket time.Duration
+}
+
+// Windowed reports whether the hits are restricted to a time window
+func (q StatsQuery) Windowed() bool {
+	return !q.Since.IsZero() || !q.Until.IsZero()
+}
+
+func (q StatsQuery) Validate() error {
+	if q.Top <= 0 || q.Top > MaxStatsTop {
+		return errors.BadRequest("invalid_input", "top must be between 1 and %d", MaxStatsTop)
+	}
+
+	if q.Offset < 0 {
+		return errors.BadRequest("invalid_input", "offset must not be negative")
+	}
+
+	if !slices.Contains(StatsSorts, q.Sort) {
+		return errors.BadRequest("invalid_input", "sort must be one of %s", strings.Join(StatsSorts, ", "))
+	}
+
+	if err := validateWindow(q.Since, q.Until); err != nil {
+		return err
+	}
+
+	return q.validateBucket()
+}
+
+// validateBucket validates the optional series bucket, which needs a lower bound
+func (q StatsQuery) validateBucket() error {
+	if q.Bucket == 0 {
+		return nil
+	}
+
+	if q.Bucket < HitsResolution || q.Bucket%HitsResolution != 0 {
+		return errors.BadRequest("invalid_input", "bucket must be a multiple of %s", HitsResolution)
+	}
+
+	if q.Since.IsZero() {
+		return errors.BadRequest("invalid_input", "bucket requires since")
+	}
+
+	until := q.Until
+	if until.IsZero() {
+		until = time.Now()
+	}
+
+	if until.Sub(q.Since)/q.Bucket >= MaxStatsBuckets {
+		return errors.BadRequest("invalid_input", "at most %d buckets are allowed", MaxStatsBuckets)
+	}
+
+	return nil
+}
+
+// FizzbuzzRequestPage is a page of the recorded requests
+type FizzbuzzRequestPage struct {
+	Requests []FizzbuzzRequest `json:"requests"`
+	// Total is the number of recorded requests, regardless of the page
+	Total int `json:"total"`
+}
+
+// HitsBucket is the number of hits recorded within a bucket of a series
+type HitsBucket struct {
+	Start time.Time `json:"start"`
+	Hits  int       `json:"hits"`
+}
+
+// Dimensions of a BreakdownQuery, named after the request parameters
+const (
+	DimensionInt1  = "int1"
+	DimensionInt2  = "int2"
+	DimensionLimit = "limit"
+	DimensionStr1  = "str1"
+	DimensionStr2  = "str2"
+	DimensionRules = "rules"
+)
+
+// Dimensions lists the accepted BreakdownQuery.Dimensions values
+var Dimensions = []string{DimensionInt1, DimensionInt2, DimensionLimit, DimensionStr1, DimensionStr2, DimensionRules}
+
+// BreakdownQuery selects a page of the hits grouped by the values of some dimensions, the most hit groups first
+type BreakdownQuery struct {
+	Dimensions []string
+	Top        int
+	Offset     int
+	// Since and Until restrict the hits to the [Since, Until) window, a zero time leaves the window open
+	Since time.Time
+	Until time.Time
+}
+
+func (q BreakdownQuery) Validate() error {
+	if len(q.Dimensions) == 0 {
+		return errors.BadRequest("invalid_input", "by must list at least one of %s", strings.Join(Dimensions, ", "))
+	}
+
+	for i, dimension := range q.Dimensions {
+		if !slices.Contains(Dimensions, dimension) {
+			return errors.BadRequest("invalid_input", "by must be one of %s", strings.Join(Dimensions, ", "))
+		}
+
+		if slices.Contains(q.Dimensions[:i], dimension) {
+			return errors.BadRequest("invalid_input", "by must not repeat %s", dimension)
+		}
+	}
+
+	if q.Top <= 0 || q.Top > MaxStatsTop {
+		return errors.BadRequest("invalid_input", "top must be between 1 and %d", MaxStatsTop)
+	}
+
+	if q.Offset < 0 {
+		return errors.BadRequest("invalid_input", "offset must not be negative")
+	}
+
+	return validateWindow(q.Since, q.Until)
+}
+
+// BreakdownGroup is the number of hits of the requests sharing the same values of the grouped dimensions
+type BreakdownGroup struct {
+	// Values maps each grouped dimension to its value
+	Values map[string]any `json:"values"`
+	Hits   int            `json:"hits"`
+	// Requests is the number of distinct requests within the group
+	Requests int `json:"requests"`
+}
+
+// BreakdownPage is a page of the groups of a breakdown
+type BreakdownPage struct {
+	Groups []BreakdownGroup `json:"groups"`
+	// Total is the number of groups, regardless of the page
+	Total int `json:"total"`
+}
+
+// LimitHistogramQuery groups the hits by ranges of limits.
+// Ranges are the powers of ten, unless a Width is given.
+type LimitHistogramQuery struct {
+	Width int
+	// Since and Until restrict the hits to the [Since, Until) window, a zero time leaves the window open
+	Since time.Time
+	Until time.Time
+}
+
+func (q LimitHistogramQuery) Validate() error {
+	if q.Width < 0 {
+		return errors.BadRequest("invalid_input", "width must be greater than 0")
+	}
+
+	return validateWindow(q.Since, q.Until)
+}
+
+// LimitBucket is the number of hits of the requests whose limit is within [Min, Max]
+type LimitBucket struct {
+	Min  int `json:"min"`
+	Max  int `json:"max"`
+	Hits int `json:"hits"`
+	// Requests is the number of distinct requests within the bucket
+	Requests int `json:"requests"`
+}
+
+// validateWindow validates an optional [since, until) window
+func validateWindow(since, until time.Time) error {
+	if !since.IsZero() && !until.IsZero() && !since.Before(until) {
+		return errors.BadRequest("invalid_input", "since must be before until")
+	}
+
+	return nil
+}
+
+// MaxTrendingWindow is the maximum window of a TrendingQuery
+const MaxTrendingWindow = 7 * 24 * time.Hour
+
+// TrendingQuery ranks the requests by the growth of their hits within [Until - Window, Until),
+// compared to the previous window of the same size
+type TrendingQuery struct {
+	Window time.Duration
+	Top    int
+	Until  time.Time
+}
+
+func (q TrendingQuery) Validate() error {
+	if q.Window < HitsResolution || q.Window%HitsResolution != 0 || q.Window > MaxTrendingWindow {
+		return errors.BadRequest("invalid_input", "window must be a multiple of %s up to %s", HitsResolution, MaxTrendingWindow)
+	}
+
+	if q.Top <= 0 || q.Top > MaxStatsTop {
+		return errors.BadRequest("invalid_input", "top must be between 1 and %d", MaxStatsTop)
+	}
+
+	return nil
+}
+
+// TrendingRequest is a request ranked by the growth of its hits
+type TrendingRequest struct {
+	FizzBuzzInput
+	// Hits and PreviousHits are the hits within the window and the previous one
+	Hits         int `json:"hits"          bun:"hits"`
+	PreviousHits int `json:"previous_hits" bun:"previous_hits"`
+	// Growth is the relative increase of the hits, counting the previous hits as at least 1
+	Growth float64 `json:"growth" bun:"growth"`
+}
@@ -4,20 +4,26 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"path/filepath"
 	"sync"
 
 	"github.com/uptrace/bun"
 	"github.com/uptrace/bun/dialect/pgdialect"
+	"github.com/uptrace/bun/dialect/sqlitedialect"
 	"github.com/uptrace/bun/driver/pgdriver"
+	"github.com/uptrace/bun/driver/sqliteshim"
+	"github.com/uptrace/bun/extra/bunotel"
 )
 
 var Clients *clients
 
 type clients struct {
-	config    Config
-	context   context.Context
-	pgSQL     *bun.DB
-	pgSQLOnce sync.Once
+	config     Config
+	context    context.Context
+	pgSQL      *bun.DB
+	pgSQLOnce  sync.Once
+	sqlite     *bun.DB
+	sqliteOnce sync.Once
 }
 
 // init /
@@ -25,6 +31,11 @@ func init() {
 	Clients = initWithConfig(prodConfig)
 }
 
+// Init replaces Clients by clients using c, before any of them is opened
+func Init(c Config) {
+	Clients = initWithConfig(c)
+}
+
 // initWithConfig /
 func initWithConfig(c Config) *clients {
 	return &clients{
@@ -38,6 +49,9 @@ func (c *clients) Close() {
 	if c.pgSQL != nil {
 		_ = c.pgSQL.Close()
 	}
+	if c.sqlite != nil {
+		_ = c.sqlite.Close()
+	}
 }
 
 // Config /
@@ -58,7 +72,26 @@ func (c *clients) PostgreSQL() *bun.DB {
 		connector.Config().Database = c.Config().Postgres.DbName
 
 		c.pgSQL = bun.NewDB(sql.OpenDB(connector), pgdialect.New())
+		c.pgSQL.AddQueryHook(bunotel.NewQueryHook(bunotel.WithDBName(c.Config().Postgres.DbName)))
 	})
 
 	return c.pgSQL
 }
+
+// SQLite opens the SQLite database, whose schema is created by the migrations
+func (c *clients) SQLite() *bun.DB {
+	c.sqliteOnce.Do(func() {
+		dsn := fmt.Sprintf("file:%s?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)", c.Config().SQLite.Path)
+		sqldb, err := sql.Open(sqliteshim.ShimName, dsn)
+		if err != nil {
+			panic(fmt.Sprintf("failed to open SQLite database: %s", err))
+		}
+		// A single connection serializes the queries, as SQLite allows a single writer at a time
+		sqldb.SetMaxOpenConns(1)
+
+		c.sqlite = bun.NewDB(sqldb, sqlitedialect.New())
+		c.sqlite.AddQueryHook(bunotel.NewQueryHook(bunotel.WithDBName(filepath.Base(c.Config().SQLite.Path))))
+	})
+
+	return c.sqlite
+}
@@ -1,21 +1,174 @@
 package internal
 
-// Config /
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// Backends storing the requests and jobs
+const (
+	BackendPostgres = "postgres"
+	// BackendSQLite stores everything in a single file, for single-node deployments and CI
+	BackendSQLite = "sqlite"
+	// BackendMemory keeps everything in memory, for tests and local runs without a database
+	BackendMemory = "memory"
+)
+
+// Exporters of the traces
+const (
+	// TracingExporterNone disables tracing
+	TracingExporterNone = "none"
+	// TracingExporterStdout prints the spans on the standard output, for local debugging
+	TracingExporterStdout = "stdout"
+	// TracingExporterFile appends the spans to a file, as JSON
+	TracingExporterFile = "file"
+	// TracingExporterOTLP sends the spans to an OpenTelemetry collector over HTTP
+	TracingExporterOTLP = "otlp"
+)
+
+// Log formats
+const (
+	LogFormatJSON    = "json"
+	LogFormatConsole = "console"
+)
+
+// Config is the configuration of the server, loaded by LoadConfig.
+// The config tags name the settings in files, environment variables and flags, see LoadConfig.
 type Config struct {
-	Postgres PostgresConfig
+	Server ServerConfig `config:"server"`
+	// Backend is the storage of the requests and jobs, one of BackendPostgres, BackendSQLite or BackendMemory
+	Backend  string         `config:"backend"`
+	Postgres PostgresConfig `config:"postgres"`
+	SQLite   SQLiteConfig   `config:"sqlite"`
+	Jobs     JobsConfig     `config:"jobs"`
+	Hits     HitsConfig     `config:"hits"`
+	// Migrations configures the schema migrations of the PostgreSQL and SQLite backends
+	Migrations MigrationsConfig `config:"migrations"`
+	Logging    LoggingConfig    `config:"logging"`
+	Limits     LimitsConfig     `config:"limits"`
+	Tracing    TracingConfig    `config:"tracing"`
+}
+
+// ServerConfig /
+type ServerConfig struct {
+	// Addr is the host:port the server listens on
+	Addr string `config:"addr"`
+	// ReadTimeout is the maximum duration for reading a request, body included
+	ReadTimeout time.Duration `config:"read_timeout"`
+	// WriteTimeout is the maximum duration for writing a response, or each chunk of a streamed one
+	WriteTimeout time.Duration `config:"write_timeout"`
+	// RequestTimeout is the deadline of the handling of each request, streamed sequences excepted,
+	// after which generation and database calls are abandoned
+	RequestTimeout time.Duration `config:"request_timeout"`
+	// IdleTimeout is the maximum time a keep-alive connection waits for the next request
+	IdleTimeout time.Duration `config:"idle_timeout"`
+	// ShutdownDelay is the time readiness fails before the server stops accepting connections when stopping,
+	// so that load balancers stop routing traffic to it
+	ShutdownDelay time.Duration `config:"shutdown_delay"`
+	// ShutdownTimeout is the time given to in-flight requests and running jobs to complete when stopping
+	ShutdownTimeout time.Duration `config:"shutdown_timeout"`
+	// ReadinessTimeout bounds each dependency check of the readiness endpoint
+	ReadinessTimeout time.Duration `config:"readiness_timeout"`
 }
 
 // PostgresConfig /
 type PostgresConfig struct {
-	Host     string
-	User     string
-	Password string
-	DbName   string
-	Port     string
+	Host     string `config:"host"`
+	User     string `config:"user"`
+	Password string `config:"password" secret:"true"`
+	DbName   string `config:"db_name"`
+	Port     string `config:"port"`
+}
+
+// SQLiteConfig /
+type SQLiteConfig struct {
+	// Path is the database file, created when missing. Relative paths are resolved from the working directory.
+	Path string `config:"path"`
+}
+
+// JobsConfig /
+type JobsConfig struct {
+	// Workers is the number of jobs running concurrently
+	Workers int `config:"workers"`
+	// QueueSize is the number of jobs waiting for a worker before new ones are rejected
+	QueueSize int `config:"queue_size"`
+	// ResultDir is the directory where job results are written
+	ResultDir string `config:"result_dir"`
+	// Retention is how long finished jobs and their result files are kept before being deleted
+	Retention time.Duration `config:"retention"`
+}
+
+// HitsConfig /
+type HitsConfig struct {
+	// FlushInterval is the maximum delay before buffered hits are written to the database
+	FlushInterval time.Duration `config:"flush_interval"`
+	// FlushSize is the number of buffered hits triggering a flush before the interval
+	FlushSize int `config:"flush_size"`
+	// Shards is the number of rows the counter of each request is spread over, 1 disabling sharding
+	Shards int `config:"shards"`
+}
+
+// MigrationsConfig /
+type MigrationsConfig struct {
+	// OnStart applies the pending migrations when the server starts.
+	// When disabled, the server refuses to start until they are applied with the migrate command.
+	OnStart bool `config:"on_start"`
 }
 
+// LoggingConfig /
+type LoggingConfig struct {
+	// Level is the minimum level of the logged messages: debug, info, warn or error
+	Level string `config:"level"`
+	// Format is LogFormatJSON or LogFormatConsole
+	Format string `config:"format"`
+}
+
+// LimitsConfig bounds the requests accepted by the API
+type LimitsConfig struct {
+	// DefaultLimit is the limit of a sequence when none is provided
+	DefaultLimit int `config:"default_limit"`
+	// MaxBodySize caps the size of JSON request bodies, in bytes
+	MaxBodySize int64 `config:"max_body_size"`
+	// MaxBatchSize is the maximum number of items of a batch request
+	MaxBatchSize int `config:"max_batch_size"`
+	// MaxTerms is the maximum number of terms of a sequence returned at once, as it is built in memory.
+	// Streamed sequences and jobs are not bounded.
+	MaxTerms int `config:"max_terms"`
+}
+
+// TracingConfig /
+type TracingConfig struct {
+	// Exporter is one of TracingExporterNone, TracingExporterStdout, TracingExporterFile or TracingExporterOTLP
+	Exporter string `config:"exporter"`
+	// File is the file the spans are appended to by TracingExporterFile
+	File string `config:"file"`
+	// Endpoint is the URL of the collector receiving the spans of TracingExporterOTLP, such as http://localhost:4318/v1/traces
+	Endpoint string `config:"endpoint"`
+}
+
+// prodConfig holds the defaults, suited to the database of docker-compose.yaml.
+// Deployments override them, the password in particular, see LoadConfig.
 var prodConfig = Config{
-	// In real production code, these values would be read from environment variables / secrets manager
+	Server: ServerConfig{
+		Addr:             ":8080",
+		ReadTimeout:      10 * time.Second,
+		WriteTimeout:     time.Minute,
+		RequestTimeout:   30 * time.Second,
+		IdleTimeout:      2 * time.Minute,
+		ShutdownDelay:    5 * time.Second,
+		ShutdownTimeout:  20 * time.Second,
+		ReadinessTimeout: 2 * time.Second,
+	},
+	Backend: BackendPostgres,
 	Postgres: PostgresConfig{
 		Host:     "localhost",
 		User:     "fizzbuzz",
@@ -23,4 +176,153 @@ var prodConfig = Config{
 		DbName:   "fizzbuzz_db",
 		Port:     "5432",
 	},
+	SQLite: SQLiteConfig{
+		Path: "fizzbuzz.db",
+	},
+	Jobs: JobsConfig{
+		Workers:   4,
+		QueueSize: 100,
+		ResultDir: "fizzbuzz-jobs",
+		Retention: 24 * time.Hour,
+	},
+	Hits: HitsConfig{
+		FlushInterval: time.Second,
+		FlushSize:     1000,
+		Shards:        1,
+	},
+	Migrations: MigrationsConfig{
+		OnStart: true,
+	},
+	Logging: LoggingConfig{
+		Level:  "info",
+		Format: LogFormatJSON,
+	},
+	Limits: LimitsConfig{
+		DefaultLimit: 100,
+		MaxBodySize:  64 * 1024,
+		MaxBatchSize: 100,
+		MaxTerms:     1_000_000,
+	},
+	Tracing: TracingConfig{
+		Exporter: TracingExporterNone,
+		File:     filepath.Join(os.TempDir(), "fizzbuzz-traces.json"),
+		Endpoint: "http://localhost:4318/v1/traces",
+	},
+}
+
+// Validate returns every invalid setting of c, joined in a single error
+func (c Config) Validate() error {
+	var errs []error
+	invalid := func(key, format string, args ...any) {
+		errs = append(errs, fmt.Errorf("%s: %s", key, fmt.Sprintf(format, args...)))
+	}
+
+	if _, _, err := net.SplitHostPort(c.Server.Addr); err != nil {
+		invalid("server.addr", "must be host:port, got %q", c.Server.Addr)
+	}
+	if c.Server.ReadTimeout <= 0 {
+		invalid("server.read_timeout", "must be positive, got %s", c.Server.ReadTimeout)
+	}
+	if c.Server.WriteTimeout <= 0 {
+		invalid("server.write_timeout", "must be positive, got %s", c.Server.WriteTimeout)
+	}
+	if c.Server.RequestTimeout <= 0 {
+		invalid("server.request_timeout", "must be positive, got %s", c.Server.RequestTimeout)
+	}
+	if c.Server.IdleTimeout <= 0 {
+		invalid("server.idle_timeout", "must be positive, got %s", c.Server.IdleTimeout)
+	}
+	if c.Server.ShutdownDelay < 0 {
+		invalid("server.shutdown_delay", "must not be negative, got %s", c.Server.ShutdownDelay)
+	}
+	if c.Server.ShutdownTimeout <= 0 {
+		invalid("server.shutdown_timeout", "must be positive, got %s", c.Server.ShutdownTimeout)
+	}
+	if c.Server.ReadinessTimeout <= 0 {
+		invalid("server.readiness_timeout", "must be positive, got %s", c.Server.ReadinessTimeout)
+	}
+
+	switch c.Backend {
+	case BackendPostgres:
+		if c.Postgres.Host == "" {
+			invalid("postgres.host", "must not be empty")
+		}
+		if port, err := strconv.Atoi(c.Postgres.Port); err != nil || port < 1 || port > math.MaxUint16 {
+			invalid("postgres.port", "must be a port number, got %q", c.Postgres.Port)
+		}
+		if c.Postgres.User == "" {
+			invalid("postgres.user", "must not be empty")
+		}
+		if c.Postgres.DbName == "" {
+			invalid("postgres.db_name", "must not be empty")
+		}
+	case BackendSQLite:
+		if c.SQLite.Path == "" {
+			invalid("sqlite.path", "must not be empty")
+		}
+	case BackendMemory:
+	default:
+		invalid("backend", "must be one of %s, %s or %s, got %q", BackendPostgres, BackendSQLite, BackendMemory, c.Backend)
+	}
+
+	if c.Jobs.Workers < 1 {
+		invalid("jobs.workers", "must be at least 1, got %d", c.Jobs.Workers)
+	}
+	if c.Jobs.QueueSize < 0 {
+		invalid("jobs.queue_size", "must not be negative, got %d", c.Jobs.QueueSize)
+	}
+	if c.Jobs.ResultDir == "" {
+		invalid("jobs.result_dir", "must not be empty")
+	}
+	if c.Jobs.Retention <= 0 {
+		invalid("jobs.retention", "must be positive, got %s", c.Jobs.Retention)
+	}
+
+	if c.Hits.FlushInterval <= 0 {
+		invalid("hits.flush_interval", "must be positive, got %s", c.Hits.FlushInterval)
+	}
+	if c.Hits.FlushSize < 1 {
+		invalid("hits.flush_size", "must be at least 1, got %d", c.Hits.FlushSize)
+	}
+	// The shard is stored in a SMALLINT column
+	if c.Hits.Shards < 1 || c.Hits.Shards > math.MaxInt16 {
+		invalid("hits.shards", "must be between 1 and %d, got %d", math.MaxInt16, c.Hits.Shards)
+	}
+
+	if _, err := zapcore.ParseLevel(c.Logging.Level); err != nil {
+		invalid("logging.level", "must be one of debug, info, warn or error, got %q", c.Logging.Level)
+	}
+	if c.Logging.Format != LogFormatJSON && c.Logging.Format != LogFormatConsole {
+		invalid("logging.format", "must be %s or %s, got %q", LogFormatJSON, LogFormatConsole, c.Logging.Format)
+	}
+
+	if c.Limits.DefaultLimit < 1 {
+		invalid("limits.default_limit", "must be at least 1, got %d", c.Limits.DefaultLimit)
+	}
+	if c.Limits.MaxBodySize < 1 {
+		invalid("limits.max_body_size", "must be at least 1, got %d", c.Limits.MaxBodySize)
+	}
+	if c.Limits.MaxBatchSize < 1 {
+		invalid("limits.max_batch_size", "must be at least 1, got %d", c.Limits.MaxBatchSize)
+	}
+	if c.Limits.MaxTerms < 1 {
+		invalid("limits.max_terms", "must be at least 1, got %d", c.Limits.MaxTerms)
+	}
+
+	switch c.Tracing.Exporter {
+	case TracingExporterNone, TracingExporterStdout:
+	case TracingExporterFile:
+		if c.Tracing.File == "" {
+			invalid("tracing.file", "must not be empty")
+		}
+	case TracingExporterOTLP:
+		if u, err := url.Parse(c.Tracing.Endpoint); err != nil || u.Scheme == "" || u.Host == "" {
+			invalid("tracing.endpoint", "must be an absolute URL, got %q", c.Tracing.Endpoint)
+		}
+	default:
+		invalid("tracing.exporter", "must be one of %s, %s, %s or %s, got %q",
+			TracingExporterNone, TracingExporterStdout, TracingExporterFile, TracingExporterOTLP, c.Tracing.Exporter)
+	}
+
+	return errors.Join(errs...)
 }
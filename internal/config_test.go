@@ -0,0 +1,187 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadConfig(t *testing.T) {
+	dir := t.TempDir()
+	writeFile := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+		return path
+	}
+	yamlFile := writeFile("config.yaml", "server:\n  addr: :9090\nhits:\n  flush_interval: 2s\n  shards: 4\npostgres:\n  host: db\n")
+	tomlFile := writeFile("config.toml", "backend = \"sqlite\"\n[sqlite]\npath = \"/data/fizzbuzz.db\"\n[migrations]\non_start = false\n")
+
+	tests := []struct {
+		name     string
+		args     []string
+		env      map[string]string
+		expected func(c *Config)
+		rest     []string
+		err      string
+	}{
+		{
+			name:     "Defaults",
+			expected: func(c *Config) {},
+		},
+		{
+			name: "YAML File",
+			args: []string{"-config", yamlFile},
+			expected: func(c *Config) {
+				c.Server.Addr = ":9090"
+				c.Hits.FlushInterval = 2 * time.Second
+				c.Hits.Shards = 4
+				c.Postgres.Host = "db"
+			},
+		},
+		{
+			name: "TOML File From Environment",
+			env:  map[string]string{"FIZZBUZZ_CONFIG": tomlFile},
+			expected: func(c *Config) {
+				c.Backend = BackendSQLite
+				c.SQLite.Path = "/data/fizzbuzz.db"
+				c.Migrations.OnStart = false
+			},
+		},
+		{
+			name: "Environment Overrides File",
+			args: []string{"-config", yamlFile},
+			env:  map[string]string{"FIZZBUZZ_HITS_SHARDS": "8", "FIZZBUZZ_POSTGRES_PASSWORD": "secret"},
+			expected: func(c *Config) {
+				c.Server.Addr = ":9090"
+				c.Hits.FlushInterval = 2 * time.Second
+				c.Hits.Shards = 8
+				c.Postgres.Host = "db"
+				c.Postgres.Password = "secret"
+			},
+		},
+		{
+			name: "Flags Override Environment",
+			args: []string{"-config", yamlFile, "-hits.shards", "16", "-logging.level=debug", "migrate", "status"},
+			env:  map[string]string{"FIZZBUZZ_HITS_SHARDS": "8"},
+			expected: func(c *Config) {
+				c.Server.Addr = ":9090"
+				c.Hits.FlushInterval = 2 * time.Second
+				c.Hits.Shards = 16
+				c.Postgres.Host = "db"
+				c.Logging.Level = "debug"
+			},
+			rest: []string{"migrate", "status"},
+		},
+		{
+			name: "Unknown File Setting",
+			args: []string{"-config", writeFile("unknown.yaml", "hits:\n  flush_every: 2s\n")},
+			err:  "unknown setting hits.flush_every",
+		},
+		{
+			name: "Unsupported File",
+			args: []string{"-config", writeFile("config.json", "{}")},
+			err:  "unsupported configuration file",
+		},
+		{
+			name: "Invalid Duration",
+			env:  map[string]string{"FIZZBUZZ_HITS_FLUSH_INTERVAL": "5"},
+			err:  "environment: hits.flush_interval: invalid duration \"5\"",
+		},
+		{
+			name: "Invalid Integer Flag",
+			args: []string{"-jobs.workers", "many"},
+			err:  "flags: jobs.workers: invalid integer \"many\"",
+		},
+		{
+			name: "Unknown Flag",
+			args: []string{"-hits.flush_every", "2s"},
+			err:  "flag provided but not defined: -hits.flush_every",
+		},
+		{
+			name: "Validation",
+			args: []string{"-backend", "mysql", "-jobs.workers", "0"},
+			err:  "invalid configuration:\nbackend: must be one of postgres, sqlite or memory, got \"mysql\"\njobs.workers: must be at least 1, got 0",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+
+			config, rest, err := LoadConfig(tt.args)
+			if tt.err != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.err)
+				return
+			}
+
+			require.NoError(t, err)
+			expected := prodConfig
+			tt.expected(&expected)
+			assert.Equal(t, expected, config)
+			if tt.rest == nil {
+				assert.Empty(t, rest)
+			} else {
+				assert.Equal(t, tt.rest, rest)
+			}
+		})
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(c *Config)
+		err    string
+	}{
+		{name: "Valid", modify: func(c *Config) {}},
+		{name: "Memory Backend Ignores Postgres", modify: func(c *Config) { c.Backend = BackendMemory; c.Postgres.Host = "" }},
+		{name: "Address", modify: func(c *Config) { c.Server.Addr = "8080" }, err: "server.addr: must be host:port, got \"8080\""},
+		{name: "Postgres Port", modify: func(c *Config) { c.Postgres.Port = "70000" }, err: "postgres.port: must be a port number, got \"70000\""},
+		{name: "SQLite Path", modify: func(c *Config) { c.Backend = BackendSQLite; c.SQLite.Path = "" }, err: "sqlite.path: must not be empty"},
+		{name: "Shards", modify: func(c *Config) { c.Hits.Shards = 40000 }, err: "hits.shards: must be between 1 and 32767, got 40000"},
+		{name: "Flush Interval", modify: func(c *Config) { c.Hits.FlushInterval = 0 }, err: "hits.flush_interval: must be positive, got 0s"},
+		{name: "Jobs Retention", modify: func(c *Config) { c.Jobs.Retention = 0 }, err: "jobs.retention: must be positive, got 0s"},
+		{name: "Log Level", modify: func(c *Config) { c.Logging.Level = "verbose" }, err: "logging.level: must be one of debug, info, warn or error, got \"verbose\""},
+		{name: "Log Format", modify: func(c *Config) { c.Logging.Format = "text" }, err: "logging.format: must be json or console, got \"text\""},
+		{name: "Tracing Exporter", modify: func(c *Config) { c.Tracing.Exporter = "jaeger" }, err: "tracing.exporter: must be one of none, stdout, file or otlp, got \"jaeger\""},
+		{name: "Tracing Endpoint", modify: func(c *Config) { c.Tracing.Exporter = TracingExporterOTLP; c.Tracing.Endpoint = "collector:4318" }, err: "tracing.endpoint: must be an absolute URL, got \"collector:4318\""},
+		{name: "Limits", modify: func(c *Config) { c.Limits.MaxBatchSize = 0 }, err: "limits.max_batch_size: must be at least 1, got 0"},
+		{name: "Max Terms", modify: func(c *Config) { c.Limits.MaxTerms = 0 }, err: "limits.max_terms: must be at least 1, got 0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := prodConfig
+			tt.modify(&config)
+
+			err := config.Validate()
+			if tt.err == "" {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Equal(t, tt.err, err.Error())
+		})
+	}
+}
+
+func TestConfigRedacted(t *testing.T) {
+	config := prodConfig
+	values := config.Redacted()
+
+	assert.Equal(t, redacted, values["postgres.password"])
+	assert.Equal(t, "fizzbuzz", values["postgres.user"])
+	assert.Equal(t, "1s", values["hits.flush_interval"])
+	assert.Equal(t, "true", values["migrations.on_start"])
+	assert.Equal(t, "fizzbuzz_password", config.Postgres.Password)
+
+	config.Postgres.Password = ""
+	assert.Empty(t, config.Redacted()["postgres.password"])
+}
@@ -0,0 +1,226 @@
+package internal
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// envPrefix prefixes the environment variables overriding settings
+const envPrefix = "FIZZBUZZ_"
+
+// redacted replaces the value of secret settings when printing the configuration
+const redacted = "[REDACTED]"
+
+// setting is a single value of Config, keyed by the config tags of its path, such as hits.flush_interval
+type setting struct {
+	key    string
+	secret bool
+	value  reflect.Value
+}
+
+// LoadConfig layers, from lowest to highest priority, the defaults, a YAML or TOML file, the environment and the flags of args.
+// The file is given by the -config flag or the FIZZBUZZ_CONFIG variable, and uses the keys of the settings as nested tables:
+//
+//	hits:
+//	  flush_interval: 2s
+//
+// The environment variable of hits.flush_interval is FIZZBUZZ_HITS_FLUSH_INTERVAL and its flag is -hits.flush_interval.
+// The loaded configuration is validated, and the arguments following the flags are returned along with it.
+func LoadConfig(args []string) (Config, []string, error) {
+	config := prodConfig
+	settings := settingsOf(&config)
+
+	flags := flag.NewFlagSet("fizzbuzz", flag.ContinueOnError)
+	path := flags.String("config", os.Getenv(envPrefix+"CONFIG"), "YAML or TOML configuration `file`, also set by "+envPrefix+"CONFIG")
+	flagValues := make(map[string]string)
+	for _, s := range settings {
+		flags.Func(s.key, "overrides "+s.key+", also set by "+s.env(), func(value string) error {
+			flagValues[s.key] = value
+			return nil
+		})
+	}
+	if err := flags.Parse(args); err != nil {
+		return Config{}, nil, err
+	}
+
+	if *path != "" {
+		fileValues, err := readConfigFile(*path)
+		if err != nil {
+			return Config{}, nil, err
+		}
+		if err := apply(settings, fileValues, *path); err != nil {
+			return Config{}, nil, err
+		}
+	}
+
+	envValues := make(map[string]string)
+	for _, s := range settings {
+		if value, ok := os.LookupEnv(s.env()); ok {
+			envValues[s.key] = value
+		}
+	}
+	if err := apply(settings, envValues, "environment"); err != nil {
+		return Config{}, nil, err
+	}
+
+	if err := apply(settings, flagValues, "flags"); err != nil {
+		return Config{}, nil, err
+	}
+
+	if err := config.Validate(); err != nil {
+		return Config{}, nil, fmt.Errorf("invalid configuration:\n%w", err)
+	}
+
+	return config, flags.Args(), nil
+}
+
+// Redacted returns every setting of c with its value, secrets being replaced so that the result can be logged
+func (c Config) Redacted() map[string]string {
+	values := make(map[string]string)
+	for _, s := range settingsOf(&c) {
+		values[s.key] = s.String()
+		if s.secret && values[s.key] != "" {
+			values[s.key] = redacted
+		}
+	}
+
+	return values
+}
+
+// settingsOf returns the settings of c, walking the nested structs of Config
+func settingsOf(c *Config) []setting {
+	var settings []setting
+	var walk func(v reflect.Value, prefix string)
+	walk = func(v reflect.Value, prefix string) {
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			key := prefix + field.Tag.Get("config")
+			if field.Type.Kind() == reflect.Struct {
+				walk(v.Field(i), key+".")
+				continue
+			}
+			settings = append(settings, setting{key: key, secret: field.Tag.Get("secret") == "true", value: v.Field(i)})
+		}
+	}
+	walk(reflect.ValueOf(c).Elem(), "")
+
+	return settings
+}
+
+// env returns the name of the environment variable of s
+func (s setting) env() string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(s.key, ".", "_"))
+}
+
+// String returns the value of s, durations being formatted like 1m30s
+func (s setting) String() string {
+	return fmt.Sprint(s.value.Interface())
+}
+
+// set parses raw into the value of s
+func (s setting) set(raw string) error {
+	if s.value.Type() == reflect.TypeOf(time.Duration(0)) {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q, expected a value such as 500ms or 1m30s", raw)
+		}
+		s.value.SetInt(int64(d))
+		return nil
+	}
+
+	switch s.value.Kind() {
+	case reflect.String:
+		s.value.SetString(raw)
+	case reflect.Int, reflect.Int64:
+		i, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", raw)
+		}
+		s.value.SetInt(i)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q, expected true or false", raw)
+		}
+		s.value.SetBool(b)
+	default:
+		return fmt.Errorf("unsupported type %s", s.value.Type())
+	}
+
+	return nil
+}
+
+// apply sets the settings named by the keys of values, source naming where they come from in errors
+func apply(settings []setting, values map[string]string, source string) error {
+	byKey := make(map[string]setting, len(settings))
+	for _, s := range settings {
+		byKey[s.key] = s
+	}
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var errs []error
+	for _, key := range keys {
+		s, ok := byKey[key]
+		if !ok {
+			errs = append(errs, fmt.Errorf("%s: unknown setting %s", source, key))
+			continue
+		}
+		if err := s.set(values[key]); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %s: %w", source, key, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// readConfigFile returns the settings of a YAML or TOML file, keyed like the settings
+func readConfigFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the configuration file: %w", err)
+	}
+
+	var tree map[string]any
+	switch filepath.Ext(path) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &tree)
+	case ".toml":
+		err = toml.Unmarshal(data, &tree)
+	default:
+		return nil, fmt.Errorf("%s: unsupported configuration file, expected a .yaml, .yml or .toml extension", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	values := make(map[string]string)
+	var flatten func(tree map[string]any, prefix string)
+	flatten = func(tree map[string]any, prefix string) {
+		for key, value := range tree {
+			if table, ok := value.(map[string]any); ok {
+				flatten(table, prefix+key+".")
+				continue
+			}
+			values[prefix+key] = fmt.Sprint(value)
+		}
+	}
+	flatten(tree, "")
+
+	return values, nil
+}
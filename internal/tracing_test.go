@@ -0,0 +1,44 @@
+package internal
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+)
+
+func TestSetupTracing(t *testing.T) {
+	previous := otel.GetTracerProvider()
+	defer otel.SetTracerProvider(previous)
+
+	t.Run("None", func(t *testing.T) {
+		shutdown, err := SetupTracing(context.Background(), TracingConfig{Exporter: TracingExporterNone})
+		require.NoError(t, err)
+		assert.Equal(t, previous, otel.GetTracerProvider())
+		assert.NoError(t, shutdown(context.Background()))
+	})
+
+	t.Run("File", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "traces.json")
+		shutdown, err := SetupTracing(context.Background(), TracingConfig{Exporter: TracingExporterFile, File: path})
+		require.NoError(t, err)
+
+		_, span := otel.Tracer("test").Start(context.Background(), "generate")
+		span.End()
+		require.NoError(t, shutdown(context.Background()))
+
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Contains(t, string(data), `"Name":"generate"`)
+		assert.Contains(t, string(data), `"Value":"fizzbuzz"`)
+	})
+
+	t.Run("Unknown Exporter", func(t *testing.T) {
+		_, err := SetupTracing(context.Background(), TracingConfig{Exporter: "jaeger"})
+		assert.EqualError(t, err, `unknown tracing exporter "jaeger"`)
+	})
+}
@@ -1,26 +1,289 @@
 package main
 
 import (
+	"context"
+	stderrors "errors"
+	"flag"
+	"fmt"
 	"lbc/fizzbuzz/api"
 	"lbc/fizzbuzz/internal"
+	"lbc/fizzbuzz/migrations"
 	"lbc/fizzbuzz/repository"
 	"lbc/fizzbuzz/service"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/mwm-io/gapi/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/uptrace/bun"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 )
 
+// flushTimeout is the maximum time spent flushing buffered hits when stopping, once requests and jobs are drained
+const flushTimeout = 5 * time.Second
+
 func main() {
-	logger := zap.NewExample()
+	config, args, err := internal.LoadConfig(os.Args[1:])
+	if stderrors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	internal.Init(config)
+
+	logger, err := newLogger(config.Logging)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	logger.Info("Loaded configuration", zap.Any("config", config.Redacted()))
+
+	if len(args) > 0 && args[0] == "migrate" {
+		runMigrate(config, args[1:], logger)
+		return
+	}
+
+	shutdownTracing, err := internal.SetupTracing(context.Background(), config.Tracing)
+	if err != nil {
+		logger.Fatal("Failed to set up tracing", zap.Error(err))
+	}
 
 	router := gin.New()
+	router.Use(otelgin.Middleware("fizzbuzz"), api.Metrics(), api.RequestTimeout(config.Server.RequestTimeout))
 
-	fizzBuzzRepository := repository.NewFizzBuzzRepository(internal.Clients.PostgreSQL(), logger)
+	storage, jobRepository := newRepositories(config, logger)
+
+	// Hits are buffered so that generating a sequence does not wait for the database
+	bufferedRepository := repository.NewBufferedFizzBuzzRepository(
+		repository.NewInstrumentedFizzBuzzRepository(storage, repository.LayerStorage), config.Hits, logger)
+	bufferedRepository.Start()
+	registerMetrics(config, bufferedRepository)
+	fizzBuzzRepository := repository.NewInstrumentedFizzBuzzRepository(bufferedRepository, repository.LayerBuffer)
 	fizzBuzzService := service.NewFizzBuzzService(fizzBuzzRepository)
-	api.SetupFizzBuzzController(logger, router, fizzBuzzService, fizzBuzzRepository)
+	api.SetupFizzBuzzController(logger, router, fizzBuzzService, config.Limits, config.Server)
+	api.SetupStatsController(logger, router, service.NewStatsService(fizzBuzzRepository))
+
+	jobService := service.NewJobService(jobRepository, fizzBuzzRepository, config.Jobs, logger)
+	if err := jobService.Start(context.Background()); err != nil {
+		logger.Error("Failed to start job service", zap.Error(err))
+	}
+	api.SetupJobController(logger, router, jobService, config.Limits)
+
+	healthService := service.NewHealthService(healthChecks(config), config.Server.ReadinessTimeout)
+	api.SetupHealthController(logger, router, healthService)
+	api.SetupMetricsController(router)
+
+	server := &http.Server{
+		Addr:         config.Server.Addr,
+		Handler:      router,
+		ReadTimeout:  config.Server.ReadTimeout,
+		WriteTimeout: config.Server.WriteTimeout,
+		IdleTimeout:  config.Server.IdleTimeout,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	go func() {
+		logger.Info("Starting server", zap.String("addr", config.Server.Addr))
+		if err := server.ListenAndServe(); err != nil && !stderrors.Is(err, http.ErrServerClosed) {
+			logger.Fatal("Failed to start server", zap.Error(err))
+		}
+	}()
+
+	<-ctx.Done()
+	// A second signal stops the process right away
+	stop()
+	logger.Info("Shutting down", zap.Stringer("delay", config.Server.ShutdownDelay), zap.Stringer("timeout", config.Server.ShutdownTimeout))
+
+	// Readiness fails while requests are still served, until load balancers stop routing traffic to the server
+	healthService.Shutdown()
+	time.Sleep(config.Server.ShutdownDelay)
+
+	// In-flight requests and running jobs share the drain deadline, jobs still running after it being queued again for the next start
+	drainCtx, cancelDrain := context.WithTimeout(context.Background(), config.Server.ShutdownTimeout)
+	defer cancelDrain()
+	if err := server.Shutdown(drainCtx); err != nil {
+		logger.Error("Failed to drain in-flight requests, closing their connections", zap.Error(err))
+		_ = server.Close()
+	}
+	jobService.Close(drainCtx)
+
+	// Hits are flushed last, as draining requests and jobs records some
+	flushCtx, cancelFlush := context.WithTimeout(context.Background(), flushTimeout)
+	defer cancelFlush()
+	if err := bufferedRepository.Close(flushCtx); err != nil {
+		logger.Error("Failed to flush buffered hits", zap.Error(err), zap.Int("lost_hits", bufferedRepository.LostHits()))
+	}
+	// Spans are exported last, as flushing hits queries the database
+	if err := shutdownTracing(flushCtx); err != nil {
+		logger.Error("Failed to export pending spans", zap.Error(err))
+	}
+
+	internal.Clients.Close()
+	logger.Info("Stopped")
+	_ = logger.Sync()
+}
+
+// newLogger returns a logger writing the messages of the configured level and above to stderr
+func newLogger(config internal.LoggingConfig) (*zap.Logger, error) {
+	level, err := zapcore.ParseLevel(config.Level)
+	if err != nil {
+		return nil, err
+	}
+
+	zapConfig := zap.NewProductionConfig()
+	zapConfig.Level = zap.NewAtomicLevelAt(level)
+	zapConfig.Encoding = config.Format
+	zapConfig.EncoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
+
+	return zapConfig.Build()
+}
+
+// newRepositories returns the repositories of the configured backend
+func newRepositories(config internal.Config, logger *zap.Logger) (repository.FizzBuzzRepository, repository.JobRepository) {
+	switch config.Backend {
+	case internal.BackendPostgres:
+		db := internal.Clients.PostgreSQL()
+		migrateOnStart(db, config.Migrations, logger)
+		return repository.NewShardedFizzBuzzRepository(db, config.Hits.Shards, logger), repository.NewJobRepository(db, logger)
+	case internal.BackendSQLite:
+		db := internal.Clients.SQLite()
+		migrateOnStart(db, config.Migrations, logger)
+		return repository.NewShardedFizzBuzzRepository(db, config.Hits.Shards, logger), repository.NewJobRepository(db, logger)
+	case internal.BackendMemory:
+		logger.Warn("Using the in-memory backend, requests and jobs are lost on restart")
+		return repository.NewMemoryFizzBuzzRepository(), repository.NewMemoryJobRepository()
+	default:
+		logger.Fatal("Unknown backend", zap.String("backend", config.Backend))
+		return nil, nil
+	}
+}
+
+// database returns the database of the backend, nil when it has none
+func database(backend string) *bun.DB {
+	switch backend {
+	case internal.BackendPostgres:
+		return internal.Clients.PostgreSQL()
+	case internal.BackendSQLite:
+		return internal.Clients.SQLite()
+	default:
+		return nil
+	}
+}
+
+// healthChecks returns the readiness checks of the database of the backend, which must be reachable and migrated
+func healthChecks(config internal.Config) map[string]service.HealthCheck {
+	db := database(config.Backend)
+	if db == nil {
+		return nil
+	}
+
+	return map[string]service.HealthCheck{
+		config.Backend: func(ctx context.Context) errors.Error {
+			if err := db.PingContext(ctx); err != nil {
+				return errors.Wrap(err).WithKind("database_unavailable")
+			}
+			return nil
+		},
+		"migrations": func(ctx context.Context) errors.Error {
+			return migrations.Check(ctx, db)
+		},
+	}
+}
+
+// registerMetrics exports the connection pool stats of the database of the backend and the number of lost hits
+func registerMetrics(config internal.Config, fizzBuzzRepository repository.BufferedFizzBuzzRepository) {
+	if db := database(config.Backend); db != nil {
+		prometheus.MustRegister(collectors.NewDBStatsCollector(db.DB, config.Backend))
+	}
+
+	prometheus.MustRegister(prometheus.NewCounterFunc(prometheus.CounterOpts{
+		Name: "fizzbuzz_hits_lost_total",
+		Help: "Number of buffered hits dropped because their flush failed.",
+	}, func() float64 {
+		return float64(fizzBuzzRepository.LostHits())
+	}))
+}
+
+// migrateOnStart applies the pending migrations when configured to, and otherwise refuses to start on an outdated schema
+func migrateOnStart(db *bun.DB, config internal.MigrationsConfig, logger *zap.Logger) {
+	ctx := context.Background()
+	if !config.OnStart {
+		if err := migrations.Check(ctx, db); err != nil {
+			logger.Fatal("The database schema is outdated, apply the migrations with the migrate command", zap.Error(err))
+		}
+		return
+	}
+
+	applied, err := migrations.Up(ctx, db)
+	if err != nil {
+		logger.Fatal("Failed to migrate the database", zap.Error(err))
+	}
+	for _, migration := range applied {
+		logger.Info("Applied migration", zap.String("migration", migration.String()))
+	}
+}
+
+// runMigrate runs the migrate command, whose argument is up (the default), down or status
+func runMigrate(config internal.Config, args []string, logger *zap.Logger) {
+	db := database(config.Backend)
+	if db == nil {
+		logger.Fatal("The backend has no schema to migrate", zap.String("backend", config.Backend))
+	}
+	defer internal.Clients.Close()
+
+	command := "up"
+	if len(args) > 0 {
+		command = args[0]
+	}
 
-	logger.Info("Starting server on :8080")
-	if err := router.Run(":8080"); err != nil {
-		logger.Fatal("Failed to start server", zap.Error(err))
+	ctx := context.Background()
+	switch command {
+	case "up":
+		applied, err := migrations.Up(ctx, db)
+		if err != nil {
+			logger.Fatal("Failed to apply the migrations", zap.Error(err))
+		}
+		if len(applied) == 0 {
+			fmt.Println("The database is up to date")
+		}
+		for _, migration := range applied {
+			fmt.Printf("Applied %s\n", migration)
+		}
+	case "down":
+		rolledBack, err := migrations.Down(ctx, db)
+		if err != nil {
+			logger.Fatal("Failed to roll back the migrations", zap.Error(err))
+		}
+		if len(rolledBack) == 0 {
+			fmt.Println("There is no migration to roll back")
+		}
+		for _, migration := range rolledBack {
+			fmt.Printf("Rolled back %s\n", migration)
+		}
+	case "status":
+		status, err := migrations.Status(ctx, db)
+		if err != nil {
+			logger.Fatal("Failed to read the migrations status", zap.Error(err))
+		}
+		for _, migration := range status {
+			if migration.IsApplied() {
+				fmt.Printf("applied  %s (group %d, %s)\n", migration, migration.GroupID, migration.MigratedAt.Format(time.RFC3339))
+			} else {
+				fmt.Printf("pending  %s\n", migration)
+			}
+		}
+	default:
+		logger.Fatal("Unknown migrate command, expected up, down or status", zap.String("command", command))
 	}
 }
@@ -0,0 +1,183 @@
+// Package migrations versions the database schema expected by the repositories.
+// Each backend has its own migrations, embedded in the binary and applied in the order of their version.
+package migrations
+
+import (
+	"context"
+	"embed"
+	"io/fs"
+	"strings"
+
+	"github.com/mwm-io/gapi/errors"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+	"github.com/uptrace/bun/migrate"
+)
+
+//go:embed postgres/*.sql sqlite/*.sql
+var files embed.FS
+
+// migrationsTable is the table where bun/migrate records the applied migrations
+const migrationsTable = "bun_migrations"
+
+// For returns the migrations of the dialect of db
+func For(db *bun.DB) (*migrate.Migrations, errors.Error) {
+	dir := "postgres"
+	if db.Dialect().Name() == dialect.SQLite {
+		dir = "sqlite"
+	}
+
+	sub, err := fs.Sub(files, dir)
+	if err != nil {
+		return nil, errors.Wrap(err).WithKind("migration_error")
+	}
+	migrations := migrate.NewMigrations()
+	if err := migrations.Discover(sub); err != nil {
+		return nil, errors.Wrap(err).WithKind("migration_error")
+	}
+
+	return migrations, nil
+}
+
+// Up applies the pending migrations, returning the ones applied.
+// A lock prevents several instances from migrating the same database at once.
+func Up(ctx context.Context, db *bun.DB) (migrate.MigrationSlice, errors.Error) {
+	migrator, gErr := newMigrator(ctx, db)
+	if gErr != nil {
+		return nil, gErr
+	}
+	if err := migrator.Lock(ctx); err != nil {
+		return nil, errors.Wrap(err).WithKind("migration_locked")
+	}
+	defer func() { _ = migrator.Unlock(ctx) }()
+
+	group, err := migrator.Migrate(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err).WithKind("migration_error").WithMessage("failed to apply migration %s: %s", lastOf(group), err)
+	}
+
+	return group.Migrations, nil
+}
+
+// Down rolls back the last applied migration, returning it.
+// Migrations are rolled back one at a time, even when applied together, so that undoing the latest change never drops the tables adopted by the first one.
+func Down(ctx context.Context, db *bun.DB) (migrate.MigrationSlice, errors.Error) {
+	migrator, gErr := newMigrator(ctx, db)
+	if gErr != nil {
+		return nil, gErr
+	}
+	if err := migrator.Lock(ctx); err != nil {
+		return nil, errors.Wrap(err).WithKind("migration_locked")
+	}
+	defer func() { _ = migrator.Unlock(ctx) }()
+
+	migrations, err := migrator.MigrationsWithStatus(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err).WithKind("migration_error")
+	}
+	applied := migrations.Applied()
+	if len(applied) == 0 {
+		return nil, nil
+	}
+
+	// The migration is only marked as unapplied once rolled back, so that a failed rollback can be retried
+	last := applied[0]
+	if last.Down != nil {
+		if err := last.Down(ctx, db); err != nil {
+			return nil, errors.Wrap(err).WithKind("migration_error").WithMessage("failed to roll back migration %s: %s", last, err)
+		}
+	}
+	if err := migrator.MarkUnapplied(ctx, &last); err != nil {
+		return nil, errors.Wrap(err).WithKind("migration_error")
+	}
+
+	return migrate.MigrationSlice{last}, nil
+}
+
+// Status returns every migration, the applied ones having an ID and a group
+func Status(ctx context.Context, db *bun.DB) (migrate.MigrationSlice, errors.Error) {
+	migrator, gErr := newMigrator(ctx, db)
+	if gErr != nil {
+		return nil, gErr
+	}
+
+	migrations, err := migrator.MigrationsWithStatus(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err).WithKind("migration_error")
+	}
+
+	return migrations, nil
+}
+
+// Check returns an error when the database is missing migrations, so that the repositories would not find the schema they expect.
+// Unlike Status, it does not create the tables of the migrations, so that it only reads the database.
+func Check(ctx context.Context, db *bun.DB) errors.Error {
+	all, gErr := For(db)
+	if gErr != nil {
+		return gErr
+	}
+
+	// A database never migrated has no table of migrations, all of them being pending
+	var migrations migrate.MigrationSlice = all.Sorted()
+	exists, gErr := migrationsTableExists(ctx, db)
+	if gErr != nil {
+		return gErr
+	}
+	if exists {
+		var err error
+		if migrations, err = migrate.NewMigrator(db, all).MigrationsWithStatus(ctx); err != nil {
+			return errors.Wrap(err).WithKind("migration_error")
+		}
+	}
+
+	pending := migrations.Unapplied()
+	if len(pending) > 0 {
+		names := make([]string, 0, len(pending))
+		for _, migration := range pending {
+			names = append(names, migration.String())
+		}
+		return errors.ServiceUnavailable("pending_migrations", "%d migrations are not applied: %s", len(pending), strings.Join(names, ", "))
+	}
+
+	return nil
+}
+
+// newMigrator returns a migrator of the migrations of db, creating its tables when missing.
+// A migration is only marked as applied once it succeeded, so that a failed one is retried by the next Up.
+func newMigrator(ctx context.Context, db *bun.DB) (*migrate.Migrator, errors.Error) {
+	migrations, gErr := For(db)
+	if gErr != nil {
+		return nil, gErr
+	}
+
+	migrator := migrate.NewMigrator(db, migrations, migrate.WithMarkAppliedOnSuccess(true))
+	if err := migrator.Init(ctx); err != nil {
+		return nil, errors.Wrap(err).WithKind("migration_error")
+	}
+
+	return migrator, nil
+}
+
+// migrationsTableExists reports whether the table recording the applied migrations was created
+func migrationsTableExists(ctx context.Context, db *bun.DB) (bool, errors.Error) {
+	query := "SELECT to_regclass(?) IS NOT NULL"
+	if db.Dialect().Name() == dialect.SQLite {
+		query = "SELECT COUNT(*) > 0 FROM sqlite_master WHERE type = 'table' AND name = ?"
+	}
+
+	var exists bool
+	if err := db.NewRaw(query, migrationsTable).Scan(ctx, &exists); err != nil {
+		return false, errors.Wrap(err).WithKind("migration_error")
+	}
+
+	return exists, nil
+}
+
+// lastOf returns the name of the last migration of group, the one that failed when migrating
+func lastOf(group *migrate.MigrationGroup) string {
+	if group == nil || len(group.Migrations) == 0 {
+		return "none"
+	}
+
+	return group.Migrations[len(group.Migrations)-1].String()
+}
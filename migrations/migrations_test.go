@@ -0,0 +1,134 @@
+package migrations_test
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"lbc/fizzbuzz/internal"
+	"lbc/fizzbuzz/migrations"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect/pgdialect"
+	"github.com/uptrace/bun/dialect/sqlitedialect"
+	"github.com/uptrace/bun/driver/pgdriver"
+	"github.com/uptrace/bun/driver/sqliteshim"
+)
+
+func TestMigrations(t *testing.T) {
+	ctx := context.Background()
+
+	// A dedicated database, as rolling back drops the tables the other packages are testing
+	sqldb, err := sql.Open(sqliteshim.ShimName, "file:"+filepath.Join(t.TempDir(), "migrations.db"))
+	require.NoError(t, err)
+	db := bun.NewDB(sqldb, sqlitedialect.New())
+	defer db.Close()
+
+	all, gErr := migrations.For(db)
+	require.Nil(t, gErr)
+	require.NotEmpty(t, all.Sorted())
+
+	// A new database is missing every migration
+	gErr = migrations.Check(ctx, db)
+	require.NotNil(t, gErr)
+	assert.Equal(t, "pending_migrations", gErr.Kind())
+
+	applied, gErr := migrations.Up(ctx, db)
+	require.Nil(t, gErr)
+	assert.Len(t, applied, len(all.Sorted()))
+	assert.Nil(t, migrations.Check(ctx, db))
+
+	_, err = db.ExecContext(ctx, "INSERT INTO fizzbuzz_requests (int1, int2, max_limit, str1, str2) VALUES (3, 5, 100, 'fizz', 'buzz')")
+	require.NoError(t, err)
+
+	// Applying the migrations again is a no-op
+	applied, gErr = migrations.Up(ctx, db)
+	require.Nil(t, gErr)
+	assert.Empty(t, applied)
+
+	status, gErr := migrations.Status(ctx, db)
+	require.Nil(t, gErr)
+	for _, migration := range status {
+		assert.True(t, migration.IsApplied(), migration.String())
+	}
+
+	// Migrations are rolled back one at a time, latest first
+	sorted := all.Sorted()
+	for i := len(sorted) - 1; i >= 0; i-- {
+		rolledBack, gErr := migrations.Down(ctx, db)
+		require.Nil(t, gErr)
+		require.Len(t, rolledBack, 1)
+		assert.Equal(t, sorted[i].Name, rolledBack[0].Name)
+		assert.NotNil(t, migrations.Check(ctx, db))
+	}
+	rolledBack, gErr := migrations.Down(ctx, db)
+	require.Nil(t, gErr)
+	assert.Empty(t, rolledBack)
+
+	var tables int
+	require.NoError(t, db.NewRaw("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name LIKE 'fizzbuzz_%'").Scan(ctx, &tables))
+	assert.Zero(t, tables)
+}
+
+func TestPostgresMigrationsUpgradeInitSchema(t *testing.T) {
+	ctx := context.Background()
+
+	// A dedicated schema, as rolling back drops the tables the other packages are testing
+	const schema = "migrations_test"
+	_, err := internal.Clients.PostgreSQL().ExecContext(ctx, "DROP SCHEMA IF EXISTS "+schema+" CASCADE; CREATE SCHEMA "+schema)
+	require.NoError(t, err)
+	defer internal.Clients.PostgreSQL().ExecContext(ctx, "DROP SCHEMA IF EXISTS "+schema+" CASCADE")
+
+	config := internal.Clients.Config().Postgres
+	connector := pgdriver.NewConnector(
+		pgdriver.WithNetwork("tcp"),
+		pgdriver.WithAddr(fmt.Sprintf("%s:%s", config.Host, config.Port)),
+		pgdriver.WithInsecure(true),
+		pgdriver.WithUser(config.User),
+		pgdriver.WithPassword(config.Password),
+		pgdriver.WithDatabase(config.DbName),
+		pgdriver.WithConnParams(map[string]interface{}{"search_path": schema}),
+	)
+	db := bun.NewDB(sql.OpenDB(connector), pgdialect.New())
+	defer db.Close()
+
+	// A database initialized by the former testdata/init.sql script
+	_, err = db.ExecContext(ctx, `CREATE TABLE fizzbuzz_requests (
+   int1 INTEGER NOT NULL,
+   int2 INTEGER NOT NULL,
+   max_limit INTEGER NOT NULL,
+   str1 VARCHAR(50) NOT NULL,
+   str2 VARCHAR(50) NOT NULL,
+   hits INTEGER DEFAULT 1,
+   PRIMARY KEY (int1, int2, max_limit, str1, str2)
+)`)
+	require.NoError(t, err)
+	_, err = db.ExecContext(ctx, "INSERT INTO fizzbuzz_requests (int1, int2, max_limit, str1, str2, hits) VALUES (3, 5, 100, 'fizz', 'buzz', 7)")
+	require.NoError(t, err)
+
+	all, gErr := migrations.For(db)
+	require.Nil(t, gErr)
+	applied, gErr := migrations.Up(ctx, db)
+	require.Nil(t, gErr)
+	assert.Len(t, applied, len(all.Sorted()))
+	assert.Nil(t, migrations.Check(ctx, db))
+
+	// The existing request is kept, without rules, and the tables of the hits and jobs are created
+	var hits int
+	require.NoError(t, db.NewRaw("SELECT SUM(hits) FROM fizzbuzz_requests WHERE rules = '[]'").Scan(ctx, &hits))
+	assert.Equal(t, 7, hits)
+	for _, table := range []string{"fizzbuzz_request_hits", "fizzbuzz_jobs"} {
+		_, err = db.ExecContext(ctx, "SELECT * FROM "+table)
+		assert.NoError(t, err, table)
+	}
+
+	// Rolling back the latest migration keeps the requests
+	rolledBack, gErr := migrations.Down(ctx, db)
+	require.Nil(t, gErr)
+	require.Len(t, rolledBack, 1)
+	require.NoError(t, db.NewRaw("SELECT SUM(hits) FROM fizzbuzz_requests").Scan(ctx, &hits))
+	assert.Equal(t, 7, hits)
+}
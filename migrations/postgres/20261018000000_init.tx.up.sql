@@ -0,0 +1,12 @@
+-- Schema of the requests as created by testdata/init.sql before migrations were versioned.
+-- The table is only created when missing, so that databases initialized by that script are adopted as is,
+-- then brought up to date by the next migrations.
+CREATE TABLE IF NOT EXISTS fizzbuzz_requests (
+   int1 INTEGER NOT NULL,
+   int2 INTEGER NOT NULL,
+   max_limit INTEGER NOT NULL,
+   str1 VARCHAR(50) NOT NULL,
+   str2 VARCHAR(50) NOT NULL,
+   hits INTEGER DEFAULT 1,
+   PRIMARY KEY (int1, int2, max_limit, str1, str2)
+);
@@ -0,0 +1,15 @@
+DROP TABLE IF EXISTS fizzbuzz_jobs;
+DROP TABLE IF EXISTS fizzbuzz_request_hits;
+
+-- Merges the requests differing only by their rules back into a single row, as the former schema has no rules
+CREATE TEMPORARY TABLE requests_without_rules ON COMMIT DROP AS
+SELECT int1, int2, max_limit, str1, str2, SUM(hits)::INTEGER AS hits
+FROM fizzbuzz_requests
+GROUP BY int1, int2, max_limit, str1, str2;
+
+DELETE FROM fizzbuzz_requests;
+ALTER TABLE fizzbuzz_requests DROP CONSTRAINT fizzbuzz_requests_pkey;
+ALTER TABLE fizzbuzz_requests DROP COLUMN rules, DROP COLUMN first_seen, DROP COLUMN last_seen;
+ALTER TABLE fizzbuzz_requests ADD PRIMARY KEY (int1, int2, max_limit, str1, str2);
+INSERT INTO fizzbuzz_requests (int1, int2, max_limit, str1, str2, hits)
+SELECT int1, int2, max_limit, str1, str2, hits FROM requests_without_rules;
@@ -0,0 +1,36 @@
+-- Brings the schema of testdata/init.sql up to date: the custom rules and the first and last hits of the requests,
+-- their hits per minute and the jobs. The existing requests have no rules and are seen for the first time now.
+ALTER TABLE fizzbuzz_requests ADD COLUMN IF NOT EXISTS rules TEXT NOT NULL DEFAULT '[]';
+ALTER TABLE fizzbuzz_requests ADD COLUMN IF NOT EXISTS first_seen TIMESTAMPTZ NOT NULL DEFAULT now();
+ALTER TABLE fizzbuzz_requests ADD COLUMN IF NOT EXISTS last_seen TIMESTAMPTZ NOT NULL DEFAULT now();
+ALTER TABLE fizzbuzz_requests DROP CONSTRAINT IF EXISTS fizzbuzz_requests_pkey;
+ALTER TABLE fizzbuzz_requests ADD PRIMARY KEY (int1, int2, max_limit, str1, str2, rules);
+
+-- Hits of each request per minute, bucket being the Unix time in seconds of the start of the minute
+CREATE TABLE IF NOT EXISTS fizzbuzz_request_hits (
+   int1 INTEGER NOT NULL,
+   int2 INTEGER NOT NULL,
+   max_limit INTEGER NOT NULL,
+   str1 VARCHAR(50) NOT NULL,
+   str2 VARCHAR(50) NOT NULL,
+   rules TEXT NOT NULL DEFAULT '[]',
+   bucket BIGINT NOT NULL,
+   hits INTEGER NOT NULL DEFAULT 1,
+   PRIMARY KEY (int1, int2, max_limit, str1, str2, rules, bucket)
+);
+
+CREATE INDEX IF NOT EXISTS fizzbuzz_request_hits_bucket_idx ON fizzbuzz_request_hits (bucket);
+
+CREATE TABLE IF NOT EXISTS fizzbuzz_jobs (
+   id VARCHAR(32) PRIMARY KEY,
+   input JSONB NOT NULL,
+   status VARCHAR(16) NOT NULL,
+   generated BIGINT NOT NULL DEFAULT 0,
+   total BIGINT NOT NULL DEFAULT 0,
+   error TEXT NOT NULL DEFAULT '',
+   created_at TIMESTAMPTZ NOT NULL,
+   started_at TIMESTAMPTZ,
+   finished_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS fizzbuzz_jobs_status_idx ON fizzbuzz_jobs (status);
@@ -0,0 +1,24 @@
+-- Merges the shards of each request back into a single row
+CREATE TEMPORARY TABLE unsharded_requests ON COMMIT DROP AS
+SELECT int1, int2, max_limit, str1, str2, rules, SUM(hits)::INTEGER AS hits, MIN(first_seen) AS first_seen, MAX(last_seen) AS last_seen
+FROM fizzbuzz_requests
+GROUP BY int1, int2, max_limit, str1, str2, rules;
+
+DELETE FROM fizzbuzz_requests;
+ALTER TABLE fizzbuzz_requests DROP CONSTRAINT fizzbuzz_requests_pkey;
+ALTER TABLE fizzbuzz_requests DROP COLUMN shard;
+ALTER TABLE fizzbuzz_requests ADD PRIMARY KEY (int1, int2, max_limit, str1, str2, rules);
+INSERT INTO fizzbuzz_requests (int1, int2, max_limit, str1, str2, rules, hits, first_seen, last_seen)
+SELECT int1, int2, max_limit, str1, str2, rules, hits, first_seen, last_seen FROM unsharded_requests;
+
+CREATE TEMPORARY TABLE unsharded_request_hits ON COMMIT DROP AS
+SELECT int1, int2, max_limit, str1, str2, rules, bucket, SUM(hits)::INTEGER AS hits
+FROM fizzbuzz_request_hits
+GROUP BY int1, int2, max_limit, str1, str2, rules, bucket;
+
+DELETE FROM fizzbuzz_request_hits;
+ALTER TABLE fizzbuzz_request_hits DROP CONSTRAINT fizzbuzz_request_hits_pkey;
+ALTER TABLE fizzbuzz_request_hits DROP COLUMN shard;
+ALTER TABLE fizzbuzz_request_hits ADD PRIMARY KEY (int1, int2, max_limit, str1, str2, rules, bucket);
+INSERT INTO fizzbuzz_request_hits (int1, int2, max_limit, str1, str2, rules, bucket, hits)
+SELECT int1, int2, max_limit, str1, str2, rules, bucket, hits FROM unsharded_request_hits;
@@ -0,0 +1,9 @@
+-- Spreads the counters of the requests over shards, the existing rows becoming the shard 0 of their request.
+-- Reads sum the shards of each request, so they return the same results before and after this migration.
+ALTER TABLE fizzbuzz_requests ADD COLUMN IF NOT EXISTS shard SMALLINT NOT NULL DEFAULT 0;
+ALTER TABLE fizzbuzz_requests DROP CONSTRAINT IF EXISTS fizzbuzz_requests_pkey;
+ALTER TABLE fizzbuzz_requests ADD PRIMARY KEY (int1, int2, max_limit, str1, str2, rules, shard);
+
+ALTER TABLE fizzbuzz_request_hits ADD COLUMN IF NOT EXISTS shard SMALLINT NOT NULL DEFAULT 0;
+ALTER TABLE fizzbuzz_request_hits DROP CONSTRAINT IF EXISTS fizzbuzz_request_hits_pkey;
+ALTER TABLE fizzbuzz_request_hits ADD PRIMARY KEY (int1, int2, max_limit, str1, str2, rules, bucket, shard);
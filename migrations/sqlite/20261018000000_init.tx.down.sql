@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS fizzbuzz_jobs;
+DROP TABLE IF EXISTS fizzbuzz_request_hits;
+DROP TABLE IF EXISTS fizzbuzz_requests;
@@ -0,0 +1,44 @@
+-- SQLite schema of the requests and jobs, the SQLite backend being sharded from the start.
+-- The tables are only created when missing, so that databases created before migrations were versioned are adopted as is.
+CREATE TABLE IF NOT EXISTS fizzbuzz_requests (
+   int1 INTEGER NOT NULL,
+   int2 INTEGER NOT NULL,
+   max_limit INTEGER NOT NULL,
+   str1 VARCHAR(50) NOT NULL,
+   str2 VARCHAR(50) NOT NULL,
+   rules TEXT NOT NULL DEFAULT '[]',
+   hits INTEGER DEFAULT 1,
+   first_seen TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+   last_seen TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+   shard SMALLINT NOT NULL DEFAULT 0,
+   PRIMARY KEY (int1, int2, max_limit, str1, str2, rules, shard)
+);
+
+CREATE TABLE IF NOT EXISTS fizzbuzz_request_hits (
+   int1 INTEGER NOT NULL,
+   int2 INTEGER NOT NULL,
+   max_limit INTEGER NOT NULL,
+   str1 VARCHAR(50) NOT NULL,
+   str2 VARCHAR(50) NOT NULL,
+   rules TEXT NOT NULL DEFAULT '[]',
+   bucket BIGINT NOT NULL,
+   shard SMALLINT NOT NULL DEFAULT 0,
+   hits INTEGER NOT NULL DEFAULT 1,
+   PRIMARY KEY (int1, int2, max_limit, str1, str2, rules, bucket, shard)
+);
+
+CREATE INDEX IF NOT EXISTS fizzbuzz_request_hits_bucket_idx ON fizzbuzz_request_hits (bucket);
+
+CREATE TABLE IF NOT EXISTS fizzbuzz_jobs (
+   id VARCHAR(32) PRIMARY KEY,
+   input JSONB NOT NULL,
+   status VARCHAR(16) NOT NULL,
+   generated BIGINT NOT NULL DEFAULT 0,
+   total BIGINT NOT NULL DEFAULT 0,
+   error TEXT NOT NULL DEFAULT '',
+   created_at TIMESTAMPTZ NOT NULL,
+   started_at TIMESTAMPTZ,
+   finished_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS fizzbuzz_jobs_status_idx ON fizzbuzz_jobs (status);
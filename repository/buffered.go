@@ -0,0 +1,236 @@
+package repository
+
+import (
+	"context"
+	"lbc/fizzbuzz/domain"
+	"lbc/fizzbuzz/internal"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mwm-io/gapi/errors"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+// maxFlushLinks caps the links from a flush to the requests whose hits it writes, as many as the SDK keeps by default
+const maxFlushLinks = 128
+
+// BufferedFizzBuzzRepository is a FizzBuzzRepository recording hits in memory, then writing them in batches.
+// Reads are served by the wrapped repository, so they miss the hits not flushed yet.
+type BufferedFizzBuzzRepository interface {
+	FizzBuzzRepository
+	// Start launches the periodic flush of the buffered hits
+	Start()
+	// Close flushes the buffered hits, hits recorded afterward are written synchronously
+	Close(ctx context.Context) errors.Error
+	// LostHits returns the number of hits dropped by failed flushes
+	LostHits() int
+}
+
+// bufferedHitsKey identifies the hits of a request within a domain.HitsResolution bucket
+type bufferedHitsKey struct {
+	request requestKey
+	bucket  int64
+}
+
+type bufferedFizzBuzzRepository struct {
+	FizzBuzzRepository
+	config internal.HitsConfig
+	logger *zap.Logger
+
+	full    chan struct{}
+	stop    chan struct{}
+	stopped chan struct{}
+	lost    atomic.Int64
+
+	mu      sync.Mutex
+	started bool
+	closed  bool
+	pending map[bufferedHitsKey]*domain.FizzbuzzRequest
+	// size is the number of buffered hits
+	size int
+	// links are the spans of the requests whose hits are buffered, linked from the span of their flush
+	links []trace.Link
+}
+
+func NewBufferedFizzBuzzRepository(
+	fizzBuzzRepository FizzBuzzRepository,
+	config internal.HitsConfig,
+	logger *zap.Logger) BufferedFizzBuzzRepository {
+	return &bufferedFizzBuzzRepository{
+		FizzBuzzRepository: fizzBuzzRepository,
+		config:             config,
+		logger:             logger,
+		full:               make(chan struct{}, 1),
+		stop:               make(chan struct{}),
+		stopped:            make(chan struct{}),
+		pending:            make(map[bufferedHitsKey]*domain.FizzbuzzRequest),
+	}
+}
+
+func (b *bufferedFizzBuzzRepository) Start() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.started || b.closed {
+		return
+	}
+	b.started = true
+
+	go b.run()
+}
+
+// run flushes the buffered hits on every tick of the interval, or earlier when the buffer is full
+func (b *bufferedFizzBuzzRepository) run() {
+	defer close(b.stopped)
+
+	ticker := time.NewTicker(b.config.FlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-b.stop:
+			return
+		case <-ticker.C:
+		case <-b.full:
+		}
+
+		_ = b.flush(context.Background())
+	}
+}
+
+func (b *bufferedFizzBuzzRepository) Close(ctx context.Context) errors.Error {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return nil
+	}
+	b.closed = true
+	started := b.started
+	b.mu.Unlock()
+
+	close(b.stop)
+	if started {
+		// Wait for an ongoing flush, so that hits are written in order
+		select {
+		case <-b.stopped:
+		case <-ctx.Done():
+		}
+	}
+
+	return b.flush(ctx)
+}
+
+func (b *bufferedFizzBuzzRepository) LostHits() int {
+	return int(b.lost.Load())
+}
+
+// Save buffers a hit for input, or writes it synchronously once closed
+func (b *bufferedFizzBuzzRepository) Save(ctx context.Context, input domain.FizzBuzzInput) errors.Error {
+	ctx, span := tracer.Start(ctx, "BufferedFizzBuzzRepository.Save")
+	err := b.record(ctx, []domain.FizzBuzzInput{input})
+	internal.EndSpan(span, err)
+
+	return err
+}
+
+// SaveBatch buffers a hit for every input, or writes them synchronously once closed
+func (b *bufferedFizzBuzzRepository) SaveBatch(ctx context.Context, inputs []domain.FizzBuzzInput) errors.Error {
+	ctx, span := tracer.Start(ctx, "BufferedFizzBuzzRepository.SaveBatch")
+	span.SetAttributes(attribute.Int("fizzbuzz.batch_size", len(inputs)))
+	err := b.record(ctx, inputs)
+	internal.EndSpan(span, err)
+
+	return err
+}
+
+// record buffers the hits of inputs, the flush writing them being linked to the span of ctx
+func (b *bufferedFizzBuzzRepository) record(ctx context.Context, inputs []domain.FizzBuzzInput) errors.Error {
+	now := time.Now()
+
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return b.FizzBuzzRepository.SaveBatch(ctx, inputs)
+	}
+
+	for _, input := range inputs {
+		key := bufferedHitsKey{request: newRequestKey(input), bucket: bucketOf(now)}
+		if hits, ok := b.pending[key]; ok {
+			hits.Hits++
+			hits.LastSeen = now
+			continue
+		}
+
+		input.Start, input.Count = 0, 0
+		b.pending[key] = &domain.FizzbuzzRequest{FizzBuzzInput: input, Hits: 1, FirstSeen: now, LastSeen: now}
+	}
+	b.size += len(inputs)
+	if spanContext := trace.SpanContextFromContext(ctx); spanContext.IsValid() && len(b.links) < maxFlushLinks {
+		b.links = append(b.links, trace.Link{SpanContext: spanContext})
+	}
+	full := b.size >= b.config.FlushSize
+	b.mu.Unlock()
+
+	if full {
+		select {
+		case b.full <- struct{}{}:
+		default:
+		}
+	}
+
+	return nil
+}
+
+// flush writes the buffered hits in a single batch.
+// When the batch fails, the requests are written one by one, so that a request the storage rejects only loses its own hits.
+// Hits that still fail are dropped and counted as lost, as retrying them could delay or duplicate them.
+// The flush has its own span, linked to the spans of the requests whose hits it writes, as it outlives them.
+func (b *bufferedFizzBuzzRepository) flush(ctx context.Context) errors.Error {
+	b.mu.Lock()
+	pending, size, links := b.pending, b.size, b.links
+	b.pending, b.size, b.links = make(map[bufferedHitsKey]*domain.FizzbuzzRequest, len(pending)), 0, nil
+	b.mu.Unlock()
+
+	if size == 0 {
+		return nil
+	}
+
+	ctx, span := tracer.Start(ctx, "BufferedFizzBuzzRepository.flush", trace.WithLinks(links...))
+	span.SetAttributes(attribute.Int("fizzbuzz.hits", size))
+
+	hits := make([]domain.FizzbuzzRequest, 0, len(pending))
+	for _, h := range pending {
+		hits = append(hits, *h)
+	}
+
+	err := b.FizzBuzzRepository.SaveHits(ctx, hits)
+	lostHits := size
+	if err != nil && len(hits) > 1 {
+		lostHits, err = b.saveEach(ctx, hits)
+	}
+	if err != nil {
+		lost := b.lost.Add(int64(lostHits))
+		b.logger.Error("Failed to flush buffered hits", zap.Error(err), zap.Int("lost_hits", lostHits), zap.Int64("total_lost_hits", lost))
+		err = errors.Wrap(err).WithKind("hits_lost").WithMessage("%d hits were lost", lostHits)
+		internal.EndSpan(span, err)
+		return err
+	}
+	internal.EndSpan(span, nil)
+
+	return nil
+}
+
+// saveEach writes the hits of every request on its own, returning the number of hits of the requests that failed and the last error
+func (b *bufferedFizzBuzzRepository) saveEach(ctx context.Context, hits []domain.FizzbuzzRequest) (int, errors.Error) {
+	var lastErr errors.Error
+	lost := 0
+	for _, h := range hits {
+		if err := b.FizzBuzzRepository.SaveHits(ctx, []domain.FizzbuzzRequest{h}); err != nil {
+			lastErr = err
+			lost += h.Hits
+		}
+	}
+
+	return lost, lastErr
+}
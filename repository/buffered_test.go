@@ -0,0 +1,182 @@
+package repository
+
+import (
+	"context"
+	stderrors "errors"
+	"lbc/fizzbuzz/domain"
+	"lbc/fizzbuzz/internal"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mwm-io/gapi/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.uber.org/zap"
+)
+
+// hitsRecorder is a FizzBuzzRepository keeping the flushed hits in memory
+type hitsRecorder struct {
+	FizzBuzzRepository
+
+	mu      sync.Mutex
+	flushes [][]domain.FizzbuzzRequest
+	batches [][]domain.FizzBuzzInput
+	err     errors.Error
+	// rejected fails the writes holding its hits, as the storage does with a request breaking its schema
+	rejected *domain.FizzBuzzInput
+}
+
+func (r *hitsRecorder) SaveHits(_ context.Context, hits []domain.FizzbuzzRequest) errors.Error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return r.err
+	}
+	for _, h := range hits {
+		if r.rejected != nil && newRequestKey(h.FizzBuzzInput) == newRequestKey(*r.rejected) {
+			return errors.Wrap(stderrors.New("value too long for type character varying(50)")).WithKind("internal_error")
+		}
+	}
+	r.flushes = append(r.flushes, hits)
+
+	return nil
+}
+
+func (r *hitsRecorder) SaveBatch(_ context.Context, inputs []domain.FizzBuzzInput) errors.Error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.batches = append(r.batches, inputs)
+
+	return nil
+}
+
+// hits returns the flushed hits of input
+func (r *hitsRecorder) hits(input domain.FizzBuzzInput) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	hits := 0
+	for _, flush := range r.flushes {
+		for _, h := range flush {
+			if newRequestKey(h.FizzBuzzInput) == newRequestKey(input) {
+				hits += h.Hits
+			}
+		}
+	}
+
+	return hits
+}
+
+func TestBufferedFizzBuzzRepository(t *testing.T) {
+	fizzBuzz := domain.FizzBuzzInput{Int1: 3, Int2: 5, Limit: 100, Str1: "fizz", Str2: "buzz"}
+	fooBar := domain.FizzBuzzInput{Int1: 2, Int2: 7, Limit: 50, Str1: "foo", Str2: "bar"}
+	windowed := fizzBuzz
+	windowed.Start, windowed.Count = 11, 10
+
+	t.Run("Close Flushes Aggregated Hits", func(t *testing.T) {
+		recorder := &hitsRecorder{}
+		repo := NewBufferedFizzBuzzRepository(recorder, internal.HitsConfig{FlushInterval: time.Hour, FlushSize: 100}, zap.NewExample())
+		repo.Start()
+
+		assert.Nil(t, repo.Save(context.Background(), fizzBuzz))
+		assert.Nil(t, repo.Save(context.Background(), windowed))
+		assert.Nil(t, repo.SaveBatch(context.Background(), []domain.FizzBuzzInput{fooBar, fizzBuzz}))
+		assert.Empty(t, recorder.flushes)
+
+		assert.Nil(t, repo.Close(context.Background()))
+		assert.Equal(t, 3, recorder.hits(fizzBuzz))
+		assert.Equal(t, 1, recorder.hits(fooBar))
+		assert.Zero(t, repo.LostHits())
+
+		// Hits are written synchronously once closed
+		assert.Nil(t, repo.Save(context.Background(), fooBar))
+		assert.Equal(t, [][]domain.FizzBuzzInput{{fooBar}}, recorder.batches)
+	})
+
+	t.Run("Size Threshold Triggers Flush", func(t *testing.T) {
+		recorder := &hitsRecorder{}
+		repo := NewBufferedFizzBuzzRepository(recorder, internal.HitsConfig{FlushInterval: time.Hour, FlushSize: 3}, zap.NewExample())
+		repo.Start()
+		defer repo.Close(context.Background())
+
+		for range 3 {
+			assert.Nil(t, repo.Save(context.Background(), fizzBuzz))
+		}
+		assert.Eventually(t, func() bool { return recorder.hits(fizzBuzz) == 3 }, time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("Interval Triggers Flush", func(t *testing.T) {
+		recorder := &hitsRecorder{}
+		repo := NewBufferedFizzBuzzRepository(recorder, internal.HitsConfig{FlushInterval: 10 * time.Millisecond, FlushSize: 100}, zap.NewExample())
+		repo.Start()
+		defer repo.Close(context.Background())
+
+		assert.Nil(t, repo.Save(context.Background(), fizzBuzz))
+		assert.Eventually(t, func() bool { return recorder.hits(fizzBuzz) == 1 }, time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("Failed Flush Reports Lost Hits", func(t *testing.T) {
+		recorder := &hitsRecorder{err: errors.Wrap(stderrors.New("connection refused")).WithKind("internal_error")}
+		repo := NewBufferedFizzBuzzRepository(recorder, internal.HitsConfig{FlushInterval: time.Hour, FlushSize: 100}, zap.NewExample())
+		repo.Start()
+
+		assert.Nil(t, repo.SaveBatch(context.Background(), []domain.FizzBuzzInput{fizzBuzz, fizzBuzz, fooBar}))
+
+		err := repo.Close(context.Background())
+		require.NotNil(t, err)
+		assert.Equal(t, "hits_lost", err.Kind())
+		assert.Equal(t, "3 hits were lost", err.Message())
+		assert.Equal(t, 3, repo.LostHits())
+	})
+
+	t.Run("Failed Flush Only Loses The Rejected Requests", func(t *testing.T) {
+		recorder := &hitsRecorder{rejected: &fooBar}
+		repo := NewBufferedFizzBuzzRepository(recorder, internal.HitsConfig{FlushInterval: time.Hour, FlushSize: 100}, zap.NewExample())
+		repo.Start()
+
+		assert.Nil(t, repo.SaveBatch(context.Background(), []domain.FizzBuzzInput{fizzBuzz, fizzBuzz, fooBar}))
+
+		err := repo.Close(context.Background())
+		require.NotNil(t, err)
+		assert.Equal(t, "1 hits were lost", err.Message())
+		assert.Equal(t, 1, repo.LostHits())
+		assert.Equal(t, 2, recorder.hits(fizzBuzz))
+	})
+}
+
+// TestBufferedFizzBuzzRepositorySpans /
+func TestBufferedFizzBuzzRepositorySpans(t *testing.T) {
+	spanRecorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spanRecorder))
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	defer otel.SetTracerProvider(previous)
+
+	fizzBuzz := domain.FizzBuzzInput{Int1: 3, Int2: 5, Limit: 100, Str1: "fizz", Str2: "buzz"}
+	repo := NewBufferedFizzBuzzRepository(&hitsRecorder{}, internal.HitsConfig{FlushInterval: time.Hour, FlushSize: 100}, zap.NewExample())
+
+	// Buffering a hit is part of the trace of the request
+	ctx, parent := provider.Tracer("test").Start(context.Background(), "handler")
+	require.Nil(t, repo.Save(ctx, fizzBuzz))
+	parent.End()
+
+	// The flush outlives the request, so it is linked to it rather than being its child
+	require.Nil(t, repo.Close(context.Background()))
+
+	spans := spanRecorder.Ended()
+	require.Len(t, spans, 3)
+	save, flush := spans[0], spans[2]
+	assert.Equal(t, "BufferedFizzBuzzRepository.Save", save.Name())
+	assert.Equal(t, parent.SpanContext().SpanID(), save.Parent().SpanID())
+
+	assert.Equal(t, "BufferedFizzBuzzRepository.flush", flush.Name())
+	assert.False(t, flush.Parent().IsValid())
+	assert.Contains(t, flush.Attributes(), attribute.Int("fizzbuzz.hits", 1))
+	require.Len(t, flush.Links(), 1)
+	assert.Equal(t, save.SpanContext(), flush.Links()[0].SpanContext)
+}
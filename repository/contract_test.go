@@ -0,0 +1,259 @@
+package repository
+
+import (
+	"context"
+	"lbc/fizzbuzz/domain"
+	"lbc/fizzbuzz/internal"
+	"lbc/fizzbuzz/testdata/utils"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestMemoryFizzBuzzRepositoryContract(t *testing.T) {
+	testFizzBuzzRepositoryContract(t, func(t *testing.T) FizzBuzzRepository {
+		return NewMemoryFizzBuzzRepository()
+	})
+}
+
+func TestPostgresFizzBuzzRepositoryContract(t *testing.T) {
+	db := internal.Clients.PostgreSQL()
+	testFizzBuzzRepositoryContract(t, func(t *testing.T) FizzBuzzRepository {
+		require.Nil(t, utils.ResetDatabase(db))
+		return NewFizzBuzzRepository(db, zap.NewExample())
+	})
+}
+
+func TestSQLiteFizzBuzzRepositoryContract(t *testing.T) {
+	db := utils.NewSQLiteDatabase(t)
+	testFizzBuzzRepositoryContract(t, func(t *testing.T) FizzBuzzRepository {
+		require.Nil(t, utils.ResetDatabase(db))
+		return NewShardedFizzBuzzRepository(db, 4, zap.NewExample())
+	})
+}
+
+func TestShardedFizzBuzzRepositoryContract(t *testing.T) {
+	db := internal.Clients.PostgreSQL()
+	testFizzBuzzRepositoryContract(t, func(t *testing.T) FizzBuzzRepository {
+		require.Nil(t, utils.ResetDatabase(db))
+		return NewShardedFizzBuzzRepository(db, 4, zap.NewExample())
+	})
+}
+
+// testFizzBuzzRepositoryContract checks the behavior every FizzBuzzRepository implementation must share.
+// newRepository must return an empty repository for every subtest.
+func testFizzBuzzRepositoryContract(t *testing.T, newRepository func(t *testing.T) FizzBuzzRepository) {
+	ctx := context.Background()
+	base := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+
+	// Rules are read back as an empty list when none was given
+	fizzBuzz := domain.FizzBuzzInput{Int1: 3, Int2: 5, Limit: 100, Str1: "fizz", Str2: "buzz", Rules: domain.Rules{}}
+	fizzBazz := domain.FizzBuzzInput{Int1: 3, Int2: 7, Limit: 200, Str1: "fizz", Str2: "bazz", Rules: domain.Rules{}}
+	fooBar := domain.FizzBuzzInput{Int1: 2, Int2: 7, Limit: 50, Str1: "foo", Str2: "bar", Rules: domain.Rules{}}
+	rules := domain.FizzBuzzInput{Limit: 30, Rules: domain.Rules{{Divisor: 3, Word: "fizz"}, {Divisor: 5, Word: "buzz"}}}
+
+	// hitsAt returns count hits of input recorded at t
+	hitsAt := func(input domain.FizzBuzzInput, count int, t time.Time) domain.FizzbuzzRequest {
+		return domain.FizzbuzzRequest{FizzBuzzInput: input, Hits: count, FirstSeen: t, LastSeen: t}
+	}
+
+	t.Run("Empty", func(t *testing.T) {
+		repo := newRepository(t)
+
+		_, err := repo.GetMostHits(ctx)
+		require.NotNil(t, err)
+		assert.Equal(t, "no_requests", err.Kind())
+		assert.Equal(t, http.StatusNotFound, err.StatusCode())
+
+		page, err := repo.ListMostHits(ctx, domain.StatsQuery{Top: 10, Sort: domain.StatsSortHits})
+		require.Nil(t, err)
+		assert.Equal(t, 0, page.Total)
+		assert.Empty(t, page.Requests)
+
+		trending, err := repo.GetTrending(ctx, domain.TrendingQuery{Window: time.Hour, Top: 10, Until: base})
+		require.Nil(t, err)
+		assert.Empty(t, trending)
+	})
+
+	t.Run("Upserts Hits", func(t *testing.T) {
+		repo := newRepository(t)
+		windowed := fizzBuzz
+		windowed.Start, windowed.Count = 11, 10
+
+		require.Nil(t, repo.Save(ctx, fizzBuzz))
+		require.Nil(t, repo.Save(ctx, windowed))
+		require.Nil(t, repo.SaveBatch(ctx, []domain.FizzBuzzInput{fizzBuzz, rules, fizzBuzz}))
+		require.Nil(t, repo.SaveBatch(ctx, []domain.FizzBuzzInput{rules}))
+
+		result, err := repo.GetMostHits(ctx)
+		require.Nil(t, err)
+		assert.Equal(t, fizzBuzz, result.FizzBuzzInput)
+		assert.Equal(t, 4, result.Hits)
+		assert.False(t, result.LastSeen.Before(result.FirstSeen))
+
+		page, err := repo.ListMostHits(ctx, domain.StatsQuery{Top: 10, Sort: domain.StatsSortHits})
+		require.Nil(t, err)
+		assert.Equal(t, 2, page.Total)
+		if assert.Len(t, page.Requests, 2) {
+			assert.Equal(t, rules, page.Requests[1].FizzBuzzInput)
+			assert.Equal(t, 2, page.Requests[1].Hits)
+		}
+	})
+
+	t.Run("Most Hits Ordering", func(t *testing.T) {
+		repo := newRepository(t)
+		require.Nil(t, repo.SaveBatch(ctx, []domain.FizzBuzzInput{fizzBuzz, fooBar, fizzBazz, fizzBazz}))
+
+		// Requests with the same hits are ordered by their parameters
+		result, err := repo.GetMostHits(ctx)
+		require.Nil(t, err)
+		assert.Equal(t, fizzBazz, result.FizzBuzzInput)
+
+		page, err := repo.ListMostHits(ctx, domain.StatsQuery{Top: 10, Sort: domain.StatsSortHits})
+		require.Nil(t, err)
+		assert.Equal(t, []domain.FizzBuzzInput{fizzBazz, fooBar, fizzBuzz}, inputsOf(page.Requests))
+
+		page, err = repo.ListMostHits(ctx, domain.StatsQuery{Top: 1, Offset: 1, Sort: domain.StatsSortLimit, Ascending: true})
+		require.Nil(t, err)
+		assert.Equal(t, 3, page.Total)
+		assert.Equal(t, []domain.FizzBuzzInput{fizzBuzz}, inputsOf(page.Requests))
+
+		page, err = repo.ListMostHits(ctx, domain.StatsQuery{Top: 10, Offset: 3, Sort: domain.StatsSortHits})
+		require.Nil(t, err)
+		assert.Equal(t, 3, page.Total)
+		assert.Empty(t, page.Requests)
+	})
+
+	t.Run("Seen Times", func(t *testing.T) {
+		repo := newRepository(t)
+		require.Nil(t, repo.SaveHits(ctx, []domain.FizzbuzzRequest{hitsAt(fizzBuzz, 1, base.Add(90*time.Second))}))
+		require.Nil(t, repo.SaveHits(ctx, []domain.FizzbuzzRequest{hitsAt(fizzBuzz, 1, base.Add(30*time.Second))}))
+		require.Nil(t, repo.SaveHits(ctx, []domain.FizzbuzzRequest{hitsAt(fooBar, 1, base.Add(time.Hour))}))
+
+		page, err := repo.ListMostHits(ctx, domain.StatsQuery{Top: 10, Sort: domain.StatsSortFirstSeen, Ascending: true})
+		require.Nil(t, err)
+		if assert.Len(t, page.Requests, 2) {
+			assert.Equal(t, fizzBuzz, page.Requests[0].FizzBuzzInput)
+			assert.Equal(t, base.Add(30*time.Second), page.Requests[0].FirstSeen.UTC())
+			assert.Equal(t, base.Add(90*time.Second), page.Requests[0].LastSeen.UTC())
+		}
+
+		page, err = repo.ListMostHits(ctx, domain.StatsQuery{Top: 10, Sort: domain.StatsSortLastSeen})
+		require.Nil(t, err)
+		assert.Equal(t, []domain.FizzBuzzInput{fooBar, fizzBuzz}, inputsOf(page.Requests))
+	})
+
+	t.Run("Time Window", func(t *testing.T) {
+		repo := newRepository(t)
+		require.Nil(t, repo.SaveHits(ctx, []domain.FizzbuzzRequest{
+			hitsAt(fizzBuzz, 3, base.Add(10*time.Second)),
+			hitsAt(fizzBuzz, 2, base.Add(time.Hour)),
+			hitsAt(fooBar, 1, base.Add(2*time.Hour)),
+		}))
+
+		page, err := repo.ListMostHits(ctx, domain.StatsQuery{Top: 10, Sort: domain.StatsSortHits, Since: base.Add(30 * time.Minute)})
+		require.Nil(t, err)
+		assert.Equal(t, 2, page.Total)
+		if assert.Len(t, page.Requests, 2) {
+			assert.Equal(t, fizzBuzz, page.Requests[0].FizzBuzzInput)
+			assert.Equal(t, 2, page.Requests[0].Hits)
+			// Within a window, times are those of the buckets
+			assert.Equal(t, base.Add(time.Hour), page.Requests[0].FirstSeen.UTC())
+		}
+
+		page, err = repo.ListMostHits(ctx, domain.StatsQuery{Top: 10, Sort: domain.StatsSortHits, Until: base.Add(time.Hour)})
+		require.Nil(t, err)
+		if assert.Len(t, page.Requests, 1) {
+			assert.Equal(t, 3, page.Requests[0].Hits)
+			assert.Equal(t, base, page.Requests[0].FirstSeen.UTC())
+		}
+
+		series, err := repo.GetHitsSeries(ctx, domain.StatsQuery{Since: base, Bucket: time.Hour})
+		require.Nil(t, err)
+		assert.Equal(t, []domain.HitsBucket{
+			{Start: base, Hits: 3},
+			{Start: base.Add(time.Hour), Hits: 2},
+			{Start: base.Add(2 * time.Hour), Hits: 1},
+		}, series)
+	})
+
+	t.Run("Breakdown And Limit Histogram", func(t *testing.T) {
+		repo := newRepository(t)
+		require.Nil(t, repo.SaveHits(ctx, []domain.FizzbuzzRequest{
+			hitsAt(fizzBuzz, 5, base),
+			hitsAt(fizzBazz, 2, base),
+			hitsAt(fooBar, 3, base),
+		}))
+
+		page, err := repo.GetBreakdown(ctx, domain.BreakdownQuery{Dimensions: []string{domain.DimensionStr1}, Top: 10})
+		require.Nil(t, err)
+		assert.Equal(t, domain.BreakdownPage{
+			Groups: []domain.BreakdownGroup{
+				{Values: map[string]any{"str1": "fizz"}, Hits: 7, Requests: 2},
+				{Values: map[string]any{"str1": "foo"}, Hits: 3, Requests: 1},
+			},
+			Total: 2,
+		}, page)
+
+		// Groups with the same hits are ordered by their values
+		page, err = repo.GetBreakdown(ctx, domain.BreakdownQuery{Dimensions: []string{domain.DimensionInt2}, Top: 1, Offset: 1})
+		require.Nil(t, err)
+		assert.Equal(t, domain.BreakdownPage{
+			Groups: []domain.BreakdownGroup{{Values: map[string]any{"int2": 7}, Hits: 5, Requests: 2}},
+			Total:  2,
+		}, page)
+
+		buckets, err := repo.GetLimitHistogram(ctx, domain.LimitHistogramQuery{})
+		require.Nil(t, err)
+		assert.Equal(t, []domain.LimitBucket{
+			{Min: 10, Max: 99, Hits: 3, Requests: 1},
+			{Min: 100, Max: 999, Hits: 7, Requests: 2},
+		}, buckets)
+
+		buckets, err = repo.GetLimitHistogram(ctx, domain.LimitHistogramQuery{Width: 100})
+		require.Nil(t, err)
+		assert.Equal(t, []domain.LimitBucket{
+			{Min: 1, Max: 100, Hits: 8, Requests: 2},
+			{Min: 101, Max: 200, Hits: 2, Requests: 1},
+		}, buckets)
+
+		buckets, err = repo.GetLimitHistogram(ctx, domain.LimitHistogramQuery{Since: base.Add(time.Minute)})
+		require.Nil(t, err)
+		assert.Empty(t, buckets)
+	})
+
+	t.Run("Trending", func(t *testing.T) {
+		repo := newRepository(t)
+		require.Nil(t, repo.SaveHits(ctx, []domain.FizzbuzzRequest{
+			hitsAt(fizzBuzz, 3, base),
+			hitsAt(fizzBuzz, 6, base.Add(time.Hour)),
+			hitsAt(fooBar, 2, base.Add(90*time.Minute)),
+			hitsAt(fizzBazz, 1, base),
+		}))
+
+		trending, err := repo.GetTrending(ctx, domain.TrendingQuery{Window: time.Hour, Top: 10, Until: base.Add(2 * time.Hour)})
+		require.Nil(t, err)
+		assert.Equal(t, []domain.TrendingRequest{
+			{FizzBuzzInput: fooBar, Hits: 2, PreviousHits: 0, Growth: 2},
+			{FizzBuzzInput: fizzBuzz, Hits: 6, PreviousHits: 3, Growth: 1},
+		}, trending)
+
+		trending, err = repo.GetTrending(ctx, domain.TrendingQuery{Window: time.Hour, Top: 1, Until: base.Add(2 * time.Hour)})
+		require.Nil(t, err)
+		assert.Len(t, trending, 1)
+	})
+}
+
+// inputsOf returns the inputs of requests, in the same order
+func inputsOf(requests []domain.FizzbuzzRequest) []domain.FizzBuzzInput {
+	inputs := make([]domain.FizzBuzzInput, len(requests))
+	for i, request := range requests {
+		inputs[i] = request.FizzBuzzInput
+	}
+
+	return inputs
+}
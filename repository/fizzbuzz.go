@@ -1,56 +1,166 @@
 package repository
 
 import (
+	"cmp"
 	"context"
+	"database/sql"
+	stderrors "errors"
 	"lbc/fizzbuzz/domain"
+	"math/rand/v2"
+	"slices"
+	"time"
 
 	"github.com/mwm-io/gapi/errors"
 	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
 	"go.uber.org/zap"
 )
 
 type FizzBuzzRepository interface {
 	Save(ctx context.Context, input domain.FizzBuzzInput) errors.Error
+	SaveBatch(ctx context.Context, inputs []domain.FizzBuzzInput) errors.Error
+	SaveHits(ctx context.Context, hits []domain.FizzbuzzRequest) errors.Error
 	GetMostHits(ctx context.Context) (domain.FizzbuzzRequest, errors.Error)
+	ListMostHits(ctx context.Context, query domain.StatsQuery) (domain.FizzbuzzRequestPage, errors.Error)
+	GetHitsSeries(ctx context.Context, query domain.StatsQuery) ([]domain.HitsBucket, errors.Error)
+	GetBreakdown(ctx context.Context, query domain.BreakdownQuery) (domain.BreakdownPage, errors.Error)
+	GetLimitHistogram(ctx context.Context, query domain.LimitHistogramQuery) ([]domain.LimitBucket, errors.Error)
+	GetTrending(ctx context.Context, query domain.TrendingQuery) ([]domain.TrendingRequest, errors.Error)
 }
 
+// requestShard is the running counter of a request within one of its shards.
+// The counter of a request is the sum of its shards, so that concurrent hits do not all update the same row.
+type requestShard struct {
+	bun.BaseModel `bun:"table:fizzbuzz_requests,alias:fizzbuzz_request"`
+	domain.FizzbuzzRequest
+	Shard int `bun:"shard"`
+}
+
+// requestHits counts the hits of a request within a domain.HitsResolution bucket and one of its shards
+type requestHits struct {
+	bun.BaseModel `bun:"table:fizzbuzz_request_hits,alias:request_hits"`
+	domain.FizzBuzzInput
+	// Bucket is the Unix time in seconds of the start of the bucket
+	Bucket int64 `bun:"bucket"`
+	Shard  int   `bun:"shard"`
+	Hits   int   `bun:"hits"`
+}
+
+// hitsBucketRow is a bucket of a series as scanned from the database
+type hitsBucketRow struct {
+	Start int64 `bun:"start"`
+	Hits  int   `bun:"hits"`
+}
+
+// requestColumns are the columns identifying a request, in primary key order
+var requestColumns = []string{"int1", "int2", "max_limit", "str1", "str2", "rules"}
+
+// statsSortColumns maps the StatsQuery sorts to their column
+var statsSortColumns = map[string]string{
+	domain.StatsSortHits:      "hits",
+	domain.StatsSortLimit:     "max_limit",
+	domain.StatsSortFirstSeen: "first_seen",
+	domain.StatsSortLastSeen:  "last_seen",
+}
+
+// tieBreakOrder orders requests equal on the sorted column by their primary key
+var tieBreakOrder = []string{"int1 ASC", "int2 ASC", "max_limit ASC", "str1 ASC", "str2 ASC", "rules ASC"}
+
 type fizzBuzzRepository struct {
 	db     *bun.DB
+	shards int
 	logger *zap.Logger
 }
 
 func NewFizzBuzzRepository(db *bun.DB, logger *zap.Logger) FizzBuzzRepository {
+	return NewShardedFizzBuzzRepository(db, 1, logger)
+}
+
+// NewShardedFizzBuzzRepository returns a FizzBuzzRepository spreading the hits of each request over shards rows.
+// Reads sum the shards whatever their number, so it can be changed at any time.
+func NewShardedFizzBuzzRepository(db *bun.DB, shards int, logger *zap.Logger) FizzBuzzRepository {
 	return &fizzBuzzRepository{
 		db:     db,
+		shards: max(shards, 1),
 		logger: logger,
 	}
 }
 
+// Save records a hit for input, both in its running counter and in its history, with a single statement
 func (f *fizzBuzzRepository) Save(ctx context.Context, input domain.FizzBuzzInput) errors.Error {
-	_, err := f.db.NewInsert().
-		Model(&domain.FizzbuzzRequest{
-			FizzBuzzInput: input,
-			Hits:          1,
-		}).
-		On("CONFLICT (int1, int2, max_limit, str1, str2) DO UPDATE SET hits = fizzbuzz_request.hits + 1").
-		Exec(ctx)
+	now := time.Now()
+
+	return f.SaveHits(ctx, []domain.FizzbuzzRequest{{FizzBuzzInput: input, Hits: 1, FirstSeen: now, LastSeen: now}})
+}
+
+// SaveBatch records a hit for every input with a single statement.
+// Identical inputs are merged first, as a row can only be updated once per statement.
+func (f *fizzBuzzRepository) SaveBatch(ctx context.Context, inputs []domain.FizzBuzzInput) errors.Error {
+	requests := aggregateHits(inputs)
+
+	now := time.Now()
+	for i := range requests {
+		requests[i].FirstSeen, requests[i].LastSeen = now, now
+	}
+
+	return f.SaveHits(ctx, requests)
+}
+
+// SaveHits records hits counted beforehand with a single statement, or a single transaction on SQLite.
+// Each element holds the hits of a request within the domain.HitsResolution bucket of its FirstSeen time,
+// and a request must appear at most once per bucket.
+func (f *fizzBuzzRepository) SaveHits(ctx context.Context, hits []domain.FizzbuzzRequest) errors.Error {
+	if len(hits) == 0 {
+		return nil
+	}
+
+	requests, history := mergeHits(hits)
+
+	shards := make([]requestShard, len(requests))
+	for i, request := range requests {
+		shards[i] = requestShard{FizzbuzzRequest: request, Shard: f.shard()}
+	}
+	for i := range history {
+		history[i].Shard = f.shard()
+	}
+
+	var err error
+	if f.sqlite() {
+		// SQLite has no data-modifying CTE, so both tables are upserted within a transaction instead
+		err = f.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+			if _, err := f.insertHistory(tx, history).Exec(ctx); err != nil {
+				return err
+			}
+			_, err := f.insertRequests(tx, shards).Exec(ctx)
+			return err
+		})
+	} else {
+		_, err = f.insertRequests(f.db, shards).
+			With("history", f.insertHistory(f.db, history)).
+			Exec(ctx)
+	}
 	if err != nil {
-		f.logger.Error("Failed to save FizzBuzzRequest", zap.Error(err))
+		f.logger.Error("Failed to save FizzBuzzRequest hits", zap.Error(err), zap.Int("size", len(requests)))
 		return errors.Wrap(err).WithKind("internal_error")
 	}
 
 	return nil
 }
 
+// GetMostHits returns the request with the most hits, or a not found error when none has been recorded
 func (f *fizzBuzzRepository) GetMostHits(ctx context.Context) (domain.FizzbuzzRequest, errors.Error) {
 	var fizzbuzzRequest domain.FizzbuzzRequest
 
 	err := f.db.NewSelect().
 		Model(&fizzbuzzRequest).
+		ModelTableExpr("(?) AS fizzbuzz_request", f.requestTotals()).
 		Order("hits DESC").
+		Order(tieBreakOrder...).
 		Limit(1).
 		Scan(ctx)
-
+	if stderrors.Is(err, sql.ErrNoRows) {
+		return fizzbuzzRequest, errors.NotFound("no_requests", "no request has been recorded yet")
+	}
 	if err != nil {
 		f.logger.Error("Failed to get most hits FizzBuzzRequest", zap.Error(err))
 		return fizzbuzzRequest, errors.Wrap(err).WithKind("internal_error")
@@ -58,3 +168,244 @@ func (f *fizzBuzzRepository) GetMostHits(ctx context.Context) (domain.FizzbuzzRe
 
 	return fizzbuzzRequest, nil
 }
+
+// ListMostHits returns a page of the recorded requests, an empty one when none matches
+func (f *fizzBuzzRepository) ListMostHits(ctx context.Context, query domain.StatsQuery) (domain.FizzbuzzRequestPage, errors.Error) {
+	direction := " DESC"
+	if query.Ascending {
+		direction = " ASC"
+	}
+
+	requests := make([]domain.FizzbuzzRequest, 0, query.Top)
+	q := f.db.NewSelect().
+		Model(&requests)
+	if query.Windowed() {
+		// Hits within the window are summed from the history, whose precision is domain.HitsResolution
+		q = q.ModelTableExpr("fizzbuzz_request_hits AS fizzbuzz_request").
+			Column(requestColumns...).
+			ColumnExpr("SUM(hits) AS hits").
+			ColumnExpr(f.unixTime("MIN(bucket)") + " AS first_seen").
+			ColumnExpr(f.unixTime("MAX(bucket)") + " AS last_seen").
+			Apply(windowFilter(query.Since, query.Until)).
+			Group(requestColumns...)
+	} else {
+		q = q.ModelTableExpr("(?) AS fizzbuzz_request", f.requestTotals())
+	}
+
+	total, err := q.
+		Order(statsSortColumns[query.Sort] + direction).
+		Order(tieBreakOrder...).
+		Limit(query.Top).
+		Offset(query.Offset).
+		ScanAndCount(ctx)
+	if err != nil {
+		f.logger.Error("Failed to list most hits FizzBuzzRequests", zap.Error(err))
+		return domain.FizzbuzzRequestPage{}, errors.Wrap(err).WithKind("internal_error")
+	}
+
+	return domain.FizzbuzzRequestPage{Requests: requests, Total: total}, nil
+}
+
+// GetHitsSeries returns the hits of all requests summed by bucket of query.Bucket, omitting empty buckets.
+// Buckets are aligned on multiples of their size since the Unix epoch.
+func (f *fizzBuzzRepository) GetHitsSeries(ctx context.Context, query domain.StatsQuery) ([]domain.HitsBucket, errors.Error) {
+	size := int64(query.Bucket / time.Second)
+
+	var rows []hitsBucketRow
+	err := f.db.NewSelect().
+		Model((*requestHits)(nil)).
+		ColumnExpr("bucket - bucket % ? AS start", size).
+		ColumnExpr("SUM(hits) AS hits").
+		Apply(windowFilter(query.Since, query.Until)).
+		GroupExpr("start").
+		OrderExpr("start ASC").
+		Scan(ctx, &rows)
+	if err != nil {
+		f.logger.Error("Failed to get hits series", zap.Error(err))
+		return nil, errors.Wrap(err).WithKind("internal_error")
+	}
+
+	series := make([]domain.HitsBucket, len(rows))
+	for i, row := range rows {
+		series[i] = domain.HitsBucket{Start: time.Unix(row.Start, 0).UTC(), Hits: row.Hits}
+	}
+
+	return series, nil
+}
+
+// insertRequests returns the upsert adding hits to the running counters of their request.
+// The first and last seen times of a request are widened to those of the inserted hits.
+func (f *fizzBuzzRepository) insertRequests(db bun.IDB, shards []requestShard) *bun.InsertQuery {
+	// LEAST and GREATEST are the multi-argument MIN and MAX of SQLite
+	least, greatest := "LEAST", "GREATEST"
+	if f.sqlite() {
+		least, greatest = "MIN", "MAX"
+	}
+
+	return db.NewInsert().
+		Model(&shards).
+		On("CONFLICT (int1, int2, max_limit, str1, str2, rules, shard) DO UPDATE").
+		Set("hits = fizzbuzz_request.hits + EXCLUDED.hits").
+		Set("first_seen = " + least + "(fizzbuzz_request.first_seen, EXCLUDED.first_seen)").
+		Set("last_seen = " + greatest + "(fizzbuzz_request.last_seen, EXCLUDED.last_seen)")
+}
+
+// insertHistory returns the upsert adding hits to the history of their request
+func (f *fizzBuzzRepository) insertHistory(db bun.IDB, history []requestHits) *bun.InsertQuery {
+	return db.NewInsert().
+		Model(&history).
+		On("CONFLICT (int1, int2, max_limit, str1, str2, rules, bucket, shard) DO UPDATE SET hits = request_hits.hits + EXCLUDED.hits")
+}
+
+// sqlite reports whether the repository runs on SQLite rather than PostgreSQL
+func (f *fizzBuzzRepository) sqlite() bool {
+	return f.db.Dialect().Name() == dialect.SQLite
+}
+
+// unixTime returns the SQL expression converting the Unix time in seconds of expr to a timestamp
+func (f *fizzBuzzRepository) unixTime(expr string) string {
+	if f.sqlite() {
+		return "datetime(" + expr + ", 'unixepoch')"
+	}
+
+	return "to_timestamp(" + expr + ")"
+}
+
+// requestTotals returns the running counters of the requests, summed across their shards
+func (f *fizzBuzzRepository) requestTotals() *bun.SelectQuery {
+	return f.db.NewSelect().
+		Model((*requestShard)(nil)).
+		Column(requestColumns...).
+		ColumnExpr("SUM(hits) AS hits").
+		ColumnExpr("MIN(first_seen) AS first_seen").
+		ColumnExpr("MAX(last_seen) AS last_seen").
+		Group(requestColumns...)
+}
+
+// shard returns the shard receiving the next hits, picked at random to spread concurrent updates
+func (f *fizzBuzzRepository) shard() int {
+	if f.shards == 1 {
+		return 0
+	}
+
+	return rand.IntN(f.shards)
+}
+
+// windowFilter restricts the history to the [since, until) window, a zero time leaving the window open
+func windowFilter(since, until time.Time) func(q *bun.SelectQuery) *bun.SelectQuery {
+	return func(q *bun.SelectQuery) *bun.SelectQuery {
+		if !since.IsZero() {
+			q = q.Where("bucket >= ?", since.Unix())
+		}
+		if !until.IsZero() {
+			q = q.Where("bucket < ?", until.Unix())
+		}
+
+		return q
+	}
+}
+
+// bucketOf returns the start of the history bucket of t
+func bucketOf(t time.Time) int64 {
+	return t.Truncate(domain.HitsResolution).Unix()
+}
+
+// requestKey identifies a persisted FizzBuzzInput
+type requestKey struct {
+	int1, int2, limit int
+	str1, str2, rules string
+}
+
+func newRequestKey(input domain.FizzBuzzInput) requestKey {
+	rules, _ := input.Rules.Value()
+
+	return requestKey{
+		int1:  input.Int1,
+		int2:  input.Int2,
+		limit: input.Limit,
+		str1:  input.Str1,
+		str2:  input.Str2,
+		rules: rules.(string),
+	}
+}
+
+// compare orders keys by their columns, in primary key order
+func (k requestKey) compare(other requestKey) int {
+	return cmp.Or(
+		cmp.Compare(k.int1, other.int1),
+		cmp.Compare(k.int2, other.int2),
+		cmp.Compare(k.limit, other.limit),
+		cmp.Compare(k.str1, other.str1),
+		cmp.Compare(k.str2, other.str2),
+		cmp.Compare(k.rules, other.rules),
+	)
+}
+
+// aggregateHits merges identical inputs into requests counting their hits.
+// Requests are sorted by key so that concurrent batches lock rows in the same order.
+func aggregateHits(inputs []domain.FizzBuzzInput) []domain.FizzbuzzRequest {
+	type keyedRequest struct {
+		key     requestKey
+		request domain.FizzbuzzRequest
+	}
+
+	indexes := make(map[requestKey]int, len(inputs))
+	keyed := make([]keyedRequest, 0, len(inputs))
+	for _, input := range inputs {
+		key := newRequestKey(input)
+		if i, ok := indexes[key]; ok {
+			keyed[i].request.Hits++
+			continue
+		}
+
+		indexes[key] = len(keyed)
+		input.Start, input.Count = 0, 0
+		keyed = append(keyed, keyedRequest{key: key, request: domain.FizzbuzzRequest{FizzBuzzInput: input, Hits: 1}})
+	}
+
+	slices.SortFunc(keyed, func(a, b keyedRequest) int { return a.key.compare(b.key) })
+
+	requests := make([]domain.FizzbuzzRequest, len(keyed))
+	for i, k := range keyed {
+		requests[i] = k.request
+	}
+
+	return requests
+}
+
+// mergeHits splits hits into the update of the running counter of each request and the rows of their history.
+// Both are sorted by key then time so that concurrent batches lock rows in the same order.
+func mergeHits(hits []domain.FizzbuzzRequest) ([]domain.FizzbuzzRequest, []requestHits) {
+	type keyedHits struct {
+		key  requestKey
+		hits domain.FizzbuzzRequest
+	}
+
+	keyed := make([]keyedHits, len(hits))
+	for i, h := range hits {
+		h.Start, h.Count = 0, 0
+		keyed[i] = keyedHits{key: newRequestKey(h.FizzBuzzInput), hits: h}
+	}
+
+	slices.SortFunc(keyed, func(a, b keyedHits) int {
+		return cmp.Or(a.key.compare(b.key), a.hits.FirstSeen.Compare(b.hits.FirstSeen))
+	})
+
+	requests := make([]domain.FizzbuzzRequest, 0, len(keyed))
+	history := make([]requestHits, len(keyed))
+	for i, k := range keyed {
+		history[i] = requestHits{FizzBuzzInput: k.hits.FizzBuzzInput, Bucket: bucketOf(k.hits.FirstSeen), Hits: k.hits.Hits}
+		if i == 0 || k.key != keyed[i-1].key {
+			requests = append(requests, k.hits)
+			continue
+		}
+
+		request := &requests[len(requests)-1]
+		request.Hits += k.hits.Hits
+		if k.hits.LastSeen.After(request.LastSeen) {
+			request.LastSeen = k.hits.LastSeen
+		}
+	}
+
+	return requests, history
+}
@@ -6,8 +6,10 @@ import (
 	"lbc/fizzbuzz/internal"
 	"lbc/fizzbuzz/testdata/utils"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"go.uber.org/zap"
 )
 
@@ -48,6 +50,15 @@ func TestFizzBuzzRepositorySave(t *testing.T) {
 			expectedHits: 2,
 			runSaveTwice: true,
 		},
+		{
+			name: "Rule List Counted Separately",
+			input: domain.FizzBuzzInput{
+				Limit: 100,
+				Rules: domain.Rules{{Divisor: 3, Word: "fizz"}, {Divisor: 5, Word: "buzz"}, {Divisor: 7, Word: "bazz"}},
+			},
+			expectedHits: 2,
+			runSaveTwice: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -63,7 +74,7 @@ func TestFizzBuzzRepositorySave(t *testing.T) {
 			var result domain.FizzbuzzRequest
 			errSQL := db.NewSelect().
 				Model(&result).
-				Where("int1 = ? AND int2 = ? AND max_limit = ? AND str1 = ? AND str2 = ?", tt.input.Int1, tt.input.Int2, tt.input.Limit, tt.input.Str1, tt.input.Str2).
+				Where("int1 = ? AND int2 = ? AND max_limit = ? AND str1 = ? AND str2 = ? AND rules = ?", tt.input.Int1, tt.input.Int2, tt.input.Limit, tt.input.Str1, tt.input.Str2, tt.input.Rules).
 				Scan(context.Background())
 			assert.Nil(t, errSQL)
 			assert.Equal(t, tt.expectedHits, result.Hits)
@@ -71,6 +82,94 @@ func TestFizzBuzzRepositorySave(t *testing.T) {
 	}
 }
 
+func TestFizzBuzzRepositorySaveBatch(t *testing.T) {
+	db := internal.Clients.PostgreSQL()
+	logger := zap.NewExample()
+	repo := NewFizzBuzzRepository(db, logger)
+
+	err := utils.ResetDatabase(db)
+	assert.Nil(t, err)
+
+	fizzBuzz := domain.FizzBuzzInput{Int1: 3, Int2: 5, Limit: 100, Str1: "fizz", Str2: "buzz"}
+	fooBar := domain.FizzBuzzInput{Int1: 2, Int2: 7, Limit: 50, Str1: "foo", Str2: "bar"}
+	windowed := fizzBuzz
+	windowed.Start, windowed.Count = 11, 10
+
+	err = repo.Save(context.Background(), fooBar)
+	assert.Nil(t, err)
+
+	err = repo.SaveBatch(context.Background(), []domain.FizzBuzzInput{fizzBuzz, fooBar, windowed})
+	assert.Nil(t, err)
+
+	tests := []struct {
+		name         string
+		input        domain.FizzBuzzInput
+		expectedHits int
+	}{
+		{
+			name:         "Duplicates In Batch Are Merged",
+			input:        fizzBuzz,
+			expectedHits: 2,
+		},
+		{
+			name:         "Existing Entry Is Incremented",
+			input:        fooBar,
+			expectedHits: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var result domain.FizzbuzzRequest
+			errSQL := db.NewSelect().
+				Model(&result).
+				Where("int1 = ? AND int2 = ? AND max_limit = ? AND str1 = ? AND str2 = ? AND rules = ?", tt.input.Int1, tt.input.Int2, tt.input.Limit, tt.input.Str1, tt.input.Str2, tt.input.Rules).
+				Scan(context.Background())
+			assert.Nil(t, errSQL)
+			assert.Equal(t, tt.expectedHits, result.Hits)
+		})
+	}
+}
+
+func TestAggregateHits(t *testing.T) {
+	fizzBuzz := domain.FizzBuzzInput{Int1: 3, Int2: 5, Limit: 100, Str1: "fizz", Str2: "buzz"}
+	windowed := fizzBuzz
+	windowed.Start = 50
+	rules := domain.FizzBuzzInput{Limit: 100, Rules: domain.Rules{{Divisor: 3, Word: "fizz"}}}
+	fooBar := domain.FizzBuzzInput{Int1: 2, Int2: 7, Limit: 50, Str1: "foo", Str2: "bar"}
+
+	result := aggregateHits([]domain.FizzBuzzInput{fizzBuzz, rules, windowed, fooBar, fizzBuzz})
+
+	assert.Equal(t, []domain.FizzbuzzRequest{
+		{FizzBuzzInput: rules, Hits: 1},
+		{FizzBuzzInput: fooBar, Hits: 1},
+		{FizzBuzzInput: fizzBuzz, Hits: 3},
+	}, result)
+}
+
+func TestMergeHits(t *testing.T) {
+	fizzBuzz := domain.FizzBuzzInput{Int1: 3, Int2: 5, Limit: 100, Str1: "fizz", Str2: "buzz"}
+	fooBar := domain.FizzBuzzInput{Int1: 2, Int2: 7, Limit: 50, Str1: "foo", Str2: "bar"}
+	first := time.Date(2024, 1, 1, 10, 0, 30, 0, time.UTC)
+	second := first.Add(time.Minute)
+
+	requests, history := mergeHits([]domain.FizzbuzzRequest{
+		{FizzBuzzInput: fizzBuzz, Hits: 2, FirstSeen: second, LastSeen: second.Add(time.Second)},
+		{FizzBuzzInput: fooBar, Hits: 1, FirstSeen: first, LastSeen: first},
+		{FizzBuzzInput: fizzBuzz, Hits: 3, FirstSeen: first, LastSeen: first.Add(time.Second)},
+	})
+
+	assert.Equal(t, []domain.FizzbuzzRequest{
+		{FizzBuzzInput: fooBar, Hits: 1, FirstSeen: first, LastSeen: first},
+		{FizzBuzzInput: fizzBuzz, Hits: 5, FirstSeen: first, LastSeen: second.Add(time.Second)},
+	}, requests)
+	assert.Equal(t, []requestHits{
+		{FizzBuzzInput: fooBar, Bucket: 1704103200, Hits: 1},
+		{FizzBuzzInput: fizzBuzz, Bucket: 1704103200, Hits: 3},
+		{FizzBuzzInput: fizzBuzz, Bucket: 1704103260, Hits: 2},
+	}, history)
+}
+
 func TestFizzBuzzRepositoryGetMostHits(t *testing.T) {
 	db := internal.Clients.PostgreSQL()
 	logger := zap.NewExample()
@@ -137,3 +236,201 @@ func TestFizzBuzzRepositoryGetMostHits(t *testing.T) {
 		})
 	}
 }
+
+func TestFizzBuzzRepositoryGetMostHitsEmpty(t *testing.T) {
+	db := internal.Clients.PostgreSQL()
+	repo := NewFizzBuzzRepository(db, zap.NewExample())
+
+	err := utils.ResetDatabase(db)
+	assert.Nil(t, err)
+
+	_, err = repo.GetMostHits(context.Background())
+	assert.NotNil(t, err)
+	assert.Equal(t, "no_requests", err.Kind())
+}
+
+func TestFizzBuzzRepositoryListMostHits(t *testing.T) {
+	db := internal.Clients.PostgreSQL()
+	repo := NewFizzBuzzRepository(db, zap.NewExample())
+
+	err := utils.ResetDatabase(db)
+	assert.Nil(t, err)
+
+	fizzBuzz := domain.FizzBuzzInput{Int1: 3, Int2: 5, Limit: 100, Str1: "fizz", Str2: "buzz"}
+	fooBar := domain.FizzBuzzInput{Int1: 2, Int2: 7, Limit: 50, Str1: "foo", Str2: "bar"}
+	fooBaz := domain.FizzBuzzInput{Int1: 2, Int2: 3, Limit: 200, Str1: "foo", Str2: "baz"}
+	err = repo.SaveBatch(context.Background(), []domain.FizzBuzzInput{fizzBuzz, fizzBuzz, fizzBuzz, fooBar, fooBaz})
+	assert.Nil(t, err)
+
+	tests := []struct {
+		name     string
+		query    domain.StatsQuery
+		expected []domain.FizzbuzzRequest
+	}{
+		{
+			name:  "Ties ordered by parameters",
+			query: domain.StatsQuery{Top: 10, Sort: domain.StatsSortHits},
+			expected: []domain.FizzbuzzRequest{
+				{FizzBuzzInput: fizzBuzz, Hits: 3},
+				{FizzBuzzInput: fooBaz, Hits: 1},
+				{FizzBuzzInput: fooBar, Hits: 1},
+			},
+		},
+		{
+			name:  "Offset",
+			query: domain.StatsQuery{Top: 1, Offset: 1, Sort: domain.StatsSortHits},
+			expected: []domain.FizzbuzzRequest{
+				{FizzBuzzInput: fooBaz, Hits: 1},
+			},
+		},
+		{
+			name:  "Ascending limit",
+			query: domain.StatsQuery{Top: 2, Sort: domain.StatsSortLimit, Ascending: true},
+			expected: []domain.FizzbuzzRequest{
+				{FizzBuzzInput: fooBar, Hits: 1},
+				{FizzBuzzInput: fizzBuzz, Hits: 3},
+			},
+		},
+		{
+			name:     "Offset past the end",
+			query:    domain.StatsQuery{Top: 10, Offset: 3, Sort: domain.StatsSortHits},
+			expected: []domain.FizzbuzzRequest{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, err := repo.ListMostHits(context.Background(), tt.query)
+			require.Nil(t, err)
+			assert.Equal(t, 3, page.Total)
+			require.Len(t, page.Requests, len(tt.expected))
+			for i, expected := range tt.expected {
+				assert.Equal(t, expected.Int1, page.Requests[i].Int1)
+				assert.Equal(t, expected.Int2, page.Requests[i].Int2)
+				assert.Equal(t, expected.Limit, page.Requests[i].Limit)
+				assert.Equal(t, expected.Hits, page.Requests[i].Hits)
+			}
+		})
+	}
+}
+
+func TestFizzBuzzRepositoryHitsHistory(t *testing.T) {
+	db := internal.Clients.PostgreSQL()
+	repo := NewFizzBuzzRepository(db, zap.NewExample())
+
+	err := utils.ResetDatabase(db)
+	assert.Nil(t, err)
+
+	fizzBuzz := domain.FizzBuzzInput{Int1: 3, Int2: 5, Limit: 100, Str1: "fizz", Str2: "buzz"}
+	fooBar := domain.FizzBuzzInput{Int1: 2, Int2: 7, Limit: 50, Str1: "foo", Str2: "bar"}
+	assert.Nil(t, repo.Save(context.Background(), fizzBuzz))
+	assert.Nil(t, repo.SaveBatch(context.Background(), []domain.FizzBuzzInput{fizzBuzz, fooBar}))
+
+	now := time.Now()
+	query := domain.StatsQuery{Top: 10, Sort: domain.StatsSortHits, Since: now.Add(-time.Hour), Bucket: time.Hour}
+
+	page, err := repo.ListMostHits(context.Background(), query)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, page.Total)
+	if assert.Len(t, page.Requests, 2) {
+		assert.Equal(t, fizzBuzz.Int2, page.Requests[0].Int2)
+		assert.Equal(t, 2, page.Requests[0].Hits)
+		assert.Equal(t, fooBar.Int2, page.Requests[1].Int2)
+		assert.Equal(t, 1, page.Requests[1].Hits)
+	}
+
+	series, err := repo.GetHitsSeries(context.Background(), query)
+	assert.Nil(t, err)
+	assert.Equal(t, []domain.HitsBucket{{Start: now.Truncate(time.Hour).UTC(), Hits: 3}}, series)
+
+	page, err = repo.ListMostHits(context.Background(), domain.StatsQuery{Top: 10, Sort: domain.StatsSortHits, Until: now.Add(-time.Hour)})
+	assert.Nil(t, err)
+	assert.Equal(t, 0, page.Total)
+	assert.Empty(t, page.Requests)
+}
+
+func TestFizzBuzzRepositorySeen(t *testing.T) {
+	db := internal.Clients.PostgreSQL()
+	repo := NewFizzBuzzRepository(db, zap.NewExample())
+
+	err := utils.ResetDatabase(db)
+	assert.Nil(t, err)
+
+	fizzBuzz := domain.FizzBuzzInput{Int1: 3, Int2: 5, Limit: 100, Str1: "fizz", Str2: "buzz"}
+	fooBar := domain.FizzBuzzInput{Int1: 2, Int2: 7, Limit: 50, Str1: "foo", Str2: "bar"}
+
+	before := time.Now()
+	assert.Nil(t, repo.Save(context.Background(), fizzBuzz))
+	time.Sleep(10 * time.Millisecond)
+	assert.Nil(t, repo.Save(context.Background(), fooBar))
+	time.Sleep(10 * time.Millisecond)
+	assert.Nil(t, repo.SaveBatch(context.Background(), []domain.FizzBuzzInput{fizzBuzz}))
+	after := time.Now()
+
+	page, err := repo.ListMostHits(context.Background(), domain.StatsQuery{Top: 10, Sort: domain.StatsSortFirstSeen})
+	assert.Nil(t, err)
+	if assert.Len(t, page.Requests, 2) {
+		// fooBar was first seen last
+		assert.Equal(t, fooBar.Int2, page.Requests[0].Int2)
+		assert.Equal(t, page.Requests[0].FirstSeen, page.Requests[0].LastSeen)
+
+		assert.Equal(t, fizzBuzz.Int2, page.Requests[1].Int2)
+		assert.WithinRange(t, page.Requests[1].FirstSeen, before.Truncate(time.Microsecond), after)
+		assert.WithinRange(t, page.Requests[1].LastSeen, page.Requests[0].LastSeen, after)
+	}
+
+	page, err = repo.ListMostHits(context.Background(), domain.StatsQuery{Top: 10, Sort: domain.StatsSortLastSeen})
+	assert.Nil(t, err)
+	if assert.Len(t, page.Requests, 2) {
+		assert.Equal(t, fizzBuzz.Int2, page.Requests[0].Int2)
+	}
+}
+
+func TestFizzBuzzRepositorySharded(t *testing.T) {
+	db := internal.Clients.PostgreSQL()
+	repo := NewShardedFizzBuzzRepository(db, 4, zap.NewExample())
+
+	err := utils.LoadFixtures(db)
+	assert.Nil(t, err)
+
+	// The fixtures are unsharded, as left by a database created before sharding
+	fizzBuzz := domain.FizzBuzzInput{Int1: 3, Int2: 5, Limit: 100, Str1: "fizz", Str2: "buzz"}
+	for range 20 {
+		assert.Nil(t, repo.Save(context.Background(), fizzBuzz))
+	}
+	assert.Nil(t, repo.SaveBatch(context.Background(), []domain.FizzBuzzInput{fizzBuzz, fizzBuzz}))
+
+	shards, errSQL := db.NewSelect().
+		Model((*requestShard)(nil)).
+		Where("int1 = ? AND int2 = ? AND max_limit = ?", fizzBuzz.Int1, fizzBuzz.Int2, fizzBuzz.Limit).
+		Count(context.Background())
+	assert.Nil(t, errSQL)
+	assert.Greater(t, shards, 1)
+
+	result, err := repo.GetMostHits(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, fizzBuzz.Int2, result.Int2)
+	assert.Equal(t, 42+22, result.Hits)
+	assert.Equal(t, time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC), result.FirstSeen.UTC())
+
+	page, err := repo.ListMostHits(context.Background(), domain.StatsQuery{Top: 10, Sort: domain.StatsSortHits})
+	assert.Nil(t, err)
+	assert.Equal(t, 4, page.Total)
+	if assert.Len(t, page.Requests, 4) {
+		assert.Equal(t, 42+22, page.Requests[0].Hits)
+	}
+
+	page, err = repo.ListMostHits(context.Background(), domain.StatsQuery{Top: 10, Sort: domain.StatsSortHits, Since: time.Now().Add(-time.Hour)})
+	assert.Nil(t, err)
+	if assert.Len(t, page.Requests, 1) {
+		assert.Equal(t, 22, page.Requests[0].Hits)
+	}
+
+	breakdown, err := repo.GetBreakdown(context.Background(), domain.BreakdownQuery{Dimensions: []string{domain.DimensionStr1}, Top: 10})
+	assert.Nil(t, err)
+	if assert.NotEmpty(t, breakdown.Groups) {
+		assert.Equal(t, "fizz", breakdown.Groups[0].Values[domain.DimensionStr1])
+		assert.Equal(t, 42+22+30, breakdown.Groups[0].Hits)
+		assert.Equal(t, 2, breakdown.Groups[0].Requests)
+	}
+}
@@ -0,0 +1,88 @@
+package repository
+
+import (
+	"context"
+	"lbc/fizzbuzz/domain"
+	"net/http"
+	"time"
+
+	"github.com/mwm-io/gapi/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const (
+	// LayerBuffer labels the calls made while handling the requests, to the repository buffering their hits
+	LayerBuffer = "buffer"
+	// LayerStorage labels the calls to the database, the flushes of the buffered hits in particular
+	LayerStorage = "storage"
+)
+
+var (
+	callDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "fizzbuzz_repository_call_duration_seconds",
+		Help:    "Duration of the calls to the FizzBuzz repository, by layer and method.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"layer", "method"})
+	callErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "fizzbuzz_repository_errors_total",
+		Help: "Number of calls to the FizzBuzz repository that failed, by layer and method.",
+	}, []string{"layer", "method"})
+)
+
+// instrumentedFizzBuzzRepository measures the latency and errors of the calls writing hits and reading the most hit request
+type instrumentedFizzBuzzRepository struct {
+	FizzBuzzRepository
+	layer string
+}
+
+// NewInstrumentedFizzBuzzRepository wraps repo to export metrics of its calls, labelled with layer.
+// Wrapping both the buffered repository and its storage tells the latency seen by the requests from the one of the database.
+func NewInstrumentedFizzBuzzRepository(repo FizzBuzzRepository, layer string) FizzBuzzRepository {
+	return &instrumentedFizzBuzzRepository{FizzBuzzRepository: repo, layer: layer}
+}
+
+func (r *instrumentedFizzBuzzRepository) Save(ctx context.Context, input domain.FizzBuzzInput) errors.Error {
+	start := time.Now()
+	err := r.FizzBuzzRepository.Save(ctx, input)
+	r.observeCall("Save", start, err)
+
+	return err
+}
+
+func (r *instrumentedFizzBuzzRepository) SaveBatch(ctx context.Context, inputs []domain.FizzBuzzInput) errors.Error {
+	start := time.Now()
+	err := r.FizzBuzzRepository.SaveBatch(ctx, inputs)
+	r.observeCall("SaveBatch", start, err)
+
+	return err
+}
+
+func (r *instrumentedFizzBuzzRepository) SaveHits(ctx context.Context, hits []domain.FizzbuzzRequest) errors.Error {
+	start := time.Now()
+	err := r.FizzBuzzRepository.SaveHits(ctx, hits)
+	r.observeCall("SaveHits", start, err)
+
+	return err
+}
+
+func (r *instrumentedFizzBuzzRepository) GetMostHits(ctx context.Context) (domain.FizzbuzzRequest, errors.Error) {
+	start := time.Now()
+	request, err := r.FizzBuzzRepository.GetMostHits(ctx)
+	// Having no request recorded yet is an answer rather than a failure
+	if err != nil && err.StatusCode() == http.StatusNotFound {
+		r.observeCall("GetMostHits", start, nil)
+	} else {
+		r.observeCall("GetMostHits", start, err)
+	}
+
+	return request, err
+}
+
+// observeCall records the duration of a call to method started at start, and its error if any
+func (r *instrumentedFizzBuzzRepository) observeCall(method string, start time.Time, err errors.Error) {
+	callDuration.WithLabelValues(r.layer, method).Observe(time.Since(start).Seconds())
+	if err != nil {
+		callErrors.WithLabelValues(r.layer, method).Inc()
+	}
+}
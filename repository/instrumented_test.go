@@ -0,0 +1,63 @@
+package repository
+
+import (
+	"context"
+	"lbc/fizzbuzz/domain"
+	"lbc/fizzbuzz/internal"
+	"testing"
+	"time"
+
+	"github.com/mwm-io/gapi/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestInstrumentedFizzBuzzRepository(t *testing.T) {
+	ctx := context.Background()
+	fizzBuzz := domain.FizzBuzzInput{Int1: 3, Int2: 5, Limit: 100, Str1: "fizz", Str2: "buzz"}
+
+	// The metrics are global, so the test checks how much they grow
+	calls := func(layer, method string) uint64 {
+		var metric dto.Metric
+		assert.NoError(t, callDuration.WithLabelValues(layer, method).(prometheus.Metric).Write(&metric))
+		return metric.GetHistogram().GetSampleCount()
+	}
+
+	savesBefore, getsBefore := calls(LayerStorage, "Save"), calls(LayerStorage, "GetMostHits")
+	errorsBefore := testutil.ToFloat64(callErrors.WithLabelValues(LayerStorage, "SaveHits"))
+	notFoundBefore := testutil.ToFloat64(callErrors.WithLabelValues(LayerStorage, "GetMostHits"))
+
+	repo := NewInstrumentedFizzBuzzRepository(NewMemoryFizzBuzzRepository(), LayerStorage)
+	_, err := repo.GetMostHits(ctx)
+	assert.NotNil(t, err)
+	assert.Nil(t, repo.Save(ctx, fizzBuzz))
+	request, err := repo.GetMostHits(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, request.Hits)
+
+	failing := NewInstrumentedFizzBuzzRepository(&hitsRecorder{err: errors.Err("internal_error", "connection refused")}, LayerStorage)
+	assert.NotNil(t, failing.SaveHits(ctx, []domain.FizzbuzzRequest{{FizzBuzzInput: fizzBuzz, Hits: 1}}))
+
+	assert.Equal(t, savesBefore+1, calls(LayerStorage, "Save"))
+	assert.Equal(t, getsBefore+2, calls(LayerStorage, "GetMostHits"))
+	// Having no request recorded yet is not an error
+	assert.Equal(t, notFoundBefore, testutil.ToFloat64(callErrors.WithLabelValues(LayerStorage, "GetMostHits")))
+	assert.Equal(t, errorsBefore+1, testutil.ToFloat64(callErrors.WithLabelValues(LayerStorage, "SaveHits")))
+
+	// Buffered hits are measured when recorded by the requests, then when flushed to the storage
+	bufferedSavesBefore, storageSavesBefore := calls(LayerBuffer, "Save"), calls(LayerStorage, "Save")
+	flushesBefore := calls(LayerStorage, "SaveHits")
+	buffered := NewBufferedFizzBuzzRepository(NewInstrumentedFizzBuzzRepository(NewMemoryFizzBuzzRepository(), LayerStorage),
+		internal.HitsConfig{FlushInterval: time.Hour, FlushSize: 100}, zap.NewExample())
+	instrumented := NewInstrumentedFizzBuzzRepository(buffered, LayerBuffer)
+	assert.Nil(t, instrumented.Save(ctx, fizzBuzz))
+	assert.Nil(t, instrumented.Save(ctx, fizzBuzz))
+	assert.Nil(t, buffered.Close(ctx))
+
+	assert.Equal(t, bufferedSavesBefore+2, calls(LayerBuffer, "Save"))
+	assert.Equal(t, storageSavesBefore, calls(LayerStorage, "Save"))
+	assert.Equal(t, flushesBefore+1, calls(LayerStorage, "SaveHits"))
+}
@@ -0,0 +1,122 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	stderrors "errors"
+	"lbc/fizzbuzz/domain"
+	"time"
+
+	"github.com/mwm-io/gapi/errors"
+	"github.com/uptrace/bun"
+	"go.uber.org/zap"
+)
+
+type JobRepository interface {
+	Create(ctx context.Context, job domain.FizzbuzzJob) errors.Error
+	Update(ctx context.Context, job domain.FizzbuzzJob) errors.Error
+	Get(ctx context.Context, id string) (domain.FizzbuzzJob, errors.Error)
+	ListUnfinished(ctx context.Context) ([]domain.FizzbuzzJob, errors.Error)
+	DeleteFinishedBefore(ctx context.Context, before time.Time) ([]string, errors.Error)
+}
+
+type jobRepository struct {
+	db     *bun.DB
+	logger *zap.Logger
+}
+
+func NewJobRepository(db *bun.DB, logger *zap.Logger) JobRepository {
+	return &jobRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+func (j *jobRepository) Create(ctx context.Context, job domain.FizzbuzzJob) errors.Error {
+	_, err := j.db.NewInsert().
+		Model(&job).
+		Exec(ctx)
+	if err != nil {
+		j.logger.Error("Failed to create FizzbuzzJob", zap.Error(err), zap.String("id", job.ID))
+		return errors.Wrap(err).WithKind("internal_error")
+	}
+
+	return nil
+}
+
+func (j *jobRepository) Update(ctx context.Context, job domain.FizzbuzzJob) errors.Error {
+	_, err := j.db.NewUpdate().
+		Model(&job).
+		WherePK().
+		Exec(ctx)
+	if err != nil {
+		j.logger.Error("Failed to update FizzbuzzJob", zap.Error(err), zap.String("id", job.ID))
+		return errors.Wrap(err).WithKind("internal_error")
+	}
+
+	return nil
+}
+
+func (j *jobRepository) Get(ctx context.Context, id string) (domain.FizzbuzzJob, errors.Error) {
+	var job domain.FizzbuzzJob
+
+	err := j.db.NewSelect().
+		Model(&job).
+		Where("id = ?", id).
+		Scan(ctx)
+	if stderrors.Is(err, sql.ErrNoRows) {
+		return job, errors.NotFound("job_not_found", "job %s not found", id)
+	}
+	if err != nil {
+		j.logger.Error("Failed to get FizzbuzzJob", zap.Error(err), zap.String("id", id))
+		return job, errors.Wrap(err).WithKind("internal_error")
+	}
+
+	return job, nil
+}
+
+// ListUnfinished returns the queued and running jobs, oldest first
+func (j *jobRepository) ListUnfinished(ctx context.Context) ([]domain.FizzbuzzJob, errors.Error) {
+	var jobs []domain.FizzbuzzJob
+
+	err := j.db.NewSelect().
+		Model(&jobs).
+		Where("status IN (?)", bun.In([]string{domain.JobStatusQueued, domain.JobStatusRunning})).
+		Order("created_at ASC").
+		Scan(ctx)
+	if err != nil {
+		j.logger.Error("Failed to list unfinished FizzbuzzJobs", zap.Error(err))
+		return nil, errors.Wrap(err).WithKind("internal_error")
+	}
+
+	return jobs, nil
+}
+
+// DeleteFinishedBefore deletes the jobs finished before the given time, returning their IDs
+func (j *jobRepository) DeleteFinishedBefore(ctx context.Context, before time.Time) ([]string, errors.Error) {
+	var ids []string
+
+	err := j.db.NewSelect().
+		Model((*domain.FizzbuzzJob)(nil)).
+		Column("id").
+		Where("finished_at < ?", before).
+		Scan(ctx, &ids)
+	if err != nil {
+		j.logger.Error("Failed to list expired FizzbuzzJobs", zap.Error(err))
+		return nil, errors.Wrap(err).WithKind("internal_error")
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	_, err = j.db.NewDelete().
+		Model((*domain.FizzbuzzJob)(nil)).
+		Where("id IN (?)", bun.In(ids)).
+		Exec(ctx)
+	if err != nil {
+		j.logger.Error("Failed to delete expired FizzbuzzJobs", zap.Error(err), zap.Int("count", len(ids)))
+		return nil, errors.Wrap(err).WithKind("internal_error")
+	}
+
+	return ids, nil
+}
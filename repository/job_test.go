@@ -0,0 +1,110 @@
+package repository
+
+import (
+	"context"
+	"lbc/fizzbuzz/domain"
+	"lbc/fizzbuzz/internal"
+	"lbc/fizzbuzz/testdata/utils"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestJobRepository(t *testing.T) {
+	db := internal.Clients.PostgreSQL()
+	err := utils.ResetDatabase(db)
+	require.Nil(t, err)
+
+	testJobRepositoryContract(t, NewJobRepository(db, zap.NewExample()))
+}
+
+func TestSQLiteJobRepository(t *testing.T) {
+	db := utils.NewSQLiteDatabase(t)
+	err := utils.ResetDatabase(db)
+	require.Nil(t, err)
+
+	testJobRepositoryContract(t, NewJobRepository(db, zap.NewExample()))
+}
+
+func TestMemoryJobRepository(t *testing.T) {
+	testJobRepositoryContract(t, NewMemoryJobRepository())
+}
+
+// testJobRepositoryContract checks the behavior every JobRepository implementation must share, starting from an empty repo
+func testJobRepositoryContract(t *testing.T, repo JobRepository) {
+	ctx := context.Background()
+
+	createdAt := time.Now().UTC().Truncate(time.Second)
+	queued := domain.FizzbuzzJob{
+		ID:        "queued",
+		Input:     domain.FizzBuzzInput{Int1: 3, Int2: 5, Limit: 100, Str1: "fizz", Str2: "buzz", Start: 11, Count: 10},
+		Status:    domain.JobStatusQueued,
+		Total:     10,
+		CreatedAt: createdAt,
+	}
+	running := domain.FizzbuzzJob{
+		ID:        "running",
+		Input:     domain.FizzBuzzInput{Limit: 100, Rules: domain.Rules{{Divisor: 3, Word: "fizz"}}},
+		Status:    domain.JobStatusQueued,
+		Total:     100,
+		CreatedAt: createdAt.Add(-time.Minute),
+	}
+	succeeded := domain.FizzbuzzJob{
+		ID:        "succeeded",
+		Input:     domain.FizzBuzzInput{Int1: 3, Int2: 5, Limit: 15, Str1: "fizz", Str2: "buzz"},
+		Status:    domain.JobStatusSucceeded,
+		Generated: 15,
+		Total:     15,
+		CreatedAt: createdAt,
+		// Finished jobs keep their status, only the expired ones are deleted
+		FinishedAt: &createdAt,
+	}
+	for _, job := range []domain.FizzbuzzJob{queued, running, succeeded} {
+		require.Nil(t, repo.Create(ctx, job))
+	}
+
+	running.Status = domain.JobStatusRunning
+	running.Generated = 42
+	running.StartedAt = &createdAt
+	require.Nil(t, repo.Update(ctx, running))
+
+	job, err := repo.Get(ctx, "queued")
+	require.Nil(t, err)
+	assert.Equal(t, queued.Input, job.Input)
+	assert.Equal(t, domain.JobStatusQueued, job.Status)
+	assert.Nil(t, job.StartedAt)
+
+	job, err = repo.Get(ctx, "running")
+	require.Nil(t, err)
+	assert.Equal(t, domain.JobStatusRunning, job.Status)
+	assert.Equal(t, 42, job.Generated)
+	assert.NotNil(t, job.StartedAt)
+
+	_, err = repo.Get(ctx, "unknown")
+	require.NotNil(t, err)
+	assert.Equal(t, "job_not_found", err.Kind())
+
+	jobs, err := repo.ListUnfinished(ctx)
+	require.Nil(t, err)
+	require.Len(t, jobs, 2)
+	assert.Equal(t, "running", jobs[0].ID)
+	assert.Equal(t, "queued", jobs[1].ID)
+
+	deleted, err := repo.DeleteFinishedBefore(ctx, createdAt)
+	require.Nil(t, err)
+	assert.Empty(t, deleted)
+
+	deleted, err = repo.DeleteFinishedBefore(ctx, createdAt.Add(time.Second))
+	require.Nil(t, err)
+	assert.Equal(t, []string{"succeeded"}, deleted)
+	_, err = repo.Get(ctx, "succeeded")
+	require.NotNil(t, err)
+	assert.Equal(t, "job_not_found", err.Kind())
+
+	jobs, err = repo.ListUnfinished(ctx)
+	require.Nil(t, err)
+	assert.Len(t, jobs, 2)
+}
@@ -0,0 +1,379 @@
+package repository
+
+import (
+	"cmp"
+	"context"
+	"lbc/fizzbuzz/domain"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/mwm-io/gapi/errors"
+)
+
+// memoryRequest is a recorded request with its history
+type memoryRequest struct {
+	key     requestKey
+	request domain.FizzbuzzRequest
+	// history maps the start of each domain.HitsResolution bucket to its hits
+	history map[int64]int
+}
+
+// keyedRequest is a request with its hits over a window, along with its key for ordering
+type keyedRequest struct {
+	key     requestKey
+	request domain.FizzbuzzRequest
+}
+
+type memoryFizzBuzzRepository struct {
+	mu       sync.RWMutex
+	requests map[requestKey]*memoryRequest
+}
+
+// NewMemoryFizzBuzzRepository returns a FizzBuzzRepository keeping the requests in memory, for tests and local runs.
+// It behaves like the PostgreSQL repository, except that strings are ordered by their bytes rather than a collation.
+func NewMemoryFizzBuzzRepository() FizzBuzzRepository {
+	return &memoryFizzBuzzRepository{
+		requests: make(map[requestKey]*memoryRequest),
+	}
+}
+
+func (m *memoryFizzBuzzRepository) Save(ctx context.Context, input domain.FizzBuzzInput) errors.Error {
+	return m.SaveBatch(ctx, []domain.FizzBuzzInput{input})
+}
+
+func (m *memoryFizzBuzzRepository) SaveBatch(ctx context.Context, inputs []domain.FizzBuzzInput) errors.Error {
+	requests := aggregateHits(inputs)
+
+	now := time.Now()
+	for i := range requests {
+		requests[i].FirstSeen, requests[i].LastSeen = now, now
+	}
+
+	return m.SaveHits(ctx, requests)
+}
+
+func (m *memoryFizzBuzzRepository) SaveHits(_ context.Context, hits []domain.FizzbuzzRequest) errors.Error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, h := range hits {
+		// Times are stored with the precision of the database
+		firstSeen, lastSeen := h.FirstSeen.Truncate(time.Microsecond), h.LastSeen.Truncate(time.Microsecond)
+
+		key := newRequestKey(h.FizzBuzzInput)
+		stored, ok := m.requests[key]
+		if !ok {
+			input := h.FizzBuzzInput
+			input.Start, input.Count = 0, 0
+			if input.Rules == nil {
+				input.Rules = domain.Rules{}
+			}
+
+			stored = &memoryRequest{
+				key:     key,
+				request: domain.FizzbuzzRequest{FizzBuzzInput: input, FirstSeen: firstSeen, LastSeen: lastSeen},
+				history: make(map[int64]int),
+			}
+			m.requests[key] = stored
+		}
+
+		stored.request.Hits += h.Hits
+		if firstSeen.Before(stored.request.FirstSeen) {
+			stored.request.FirstSeen = firstSeen
+		}
+		if lastSeen.After(stored.request.LastSeen) {
+			stored.request.LastSeen = lastSeen
+		}
+		stored.history[bucketOf(h.FirstSeen)] += h.Hits
+	}
+
+	return nil
+}
+
+func (m *memoryFizzBuzzRepository) GetMostHits(_ context.Context) (domain.FizzbuzzRequest, errors.Error) {
+	requests := m.hitsWithin(time.Time{}, time.Time{})
+	if len(requests) == 0 {
+		return domain.FizzbuzzRequest{}, errors.NotFound("no_requests", "no request has been recorded yet")
+	}
+
+	return slices.MinFunc(requests, func(a, b keyedRequest) int {
+		return cmp.Or(cmp.Compare(b.request.Hits, a.request.Hits), a.key.compare(b.key))
+	}).request, nil
+}
+
+func (m *memoryFizzBuzzRepository) ListMostHits(_ context.Context, query domain.StatsQuery) (domain.FizzbuzzRequestPage, errors.Error) {
+	requests := m.hitsWithin(query.Since, query.Until)
+	slices.SortFunc(requests, func(a, b keyedRequest) int {
+		c := compareStatsSort(query.Sort, a.request, b.request)
+		if !query.Ascending {
+			c = -c
+		}
+
+		return cmp.Or(c, a.key.compare(b.key))
+	})
+
+	page := domain.FizzbuzzRequestPage{Requests: make([]domain.FizzbuzzRequest, 0, query.Top), Total: len(requests)}
+	for _, r := range pageOf(requests, query.Top, query.Offset) {
+		page.Requests = append(page.Requests, r.request)
+	}
+
+	return page, nil
+}
+
+func (m *memoryFizzBuzzRepository) GetHitsSeries(_ context.Context, query domain.StatsQuery) ([]domain.HitsBucket, errors.Error) {
+	size := int64(query.Bucket / time.Second)
+
+	m.mu.RLock()
+	hits := make(map[int64]int)
+	for _, stored := range m.requests {
+		for bucket, h := range stored.history {
+			if inWindow(bucket, query.Since, query.Until) {
+				hits[bucket-bucket%size] += h
+			}
+		}
+	}
+	m.mu.RUnlock()
+
+	series := make([]domain.HitsBucket, 0, len(hits))
+	for start, h := range hits {
+		series = append(series, domain.HitsBucket{Start: time.Unix(start, 0).UTC(), Hits: h})
+	}
+	slices.SortFunc(series, func(a, b domain.HitsBucket) int { return a.Start.Compare(b.Start) })
+
+	return series, nil
+}
+
+func (m *memoryFizzBuzzRepository) GetBreakdown(_ context.Context, query domain.BreakdownQuery) (domain.BreakdownPage, errors.Error) {
+	type group struct {
+		key    requestKey
+		values domain.FizzBuzzInput
+		domain.BreakdownGroup
+	}
+
+	indexes := make(map[requestKey]int)
+	var groups []group
+	for _, r := range m.hitsWithin(query.Since, query.Until) {
+		key := dimensionsKey(r.key, query.Dimensions)
+		i, ok := indexes[key]
+		if !ok {
+			i = len(groups)
+			indexes[key] = i
+			groups = append(groups, group{key: key, values: r.request.FizzBuzzInput})
+		}
+
+		groups[i].Hits += r.request.Hits
+		groups[i].Requests++
+	}
+
+	slices.SortFunc(groups, func(a, b group) int {
+		c := cmp.Compare(b.Hits, a.Hits)
+		for _, dimension := range query.Dimensions {
+			c = cmp.Or(c, compareDimension(dimension, a.key, b.key))
+		}
+
+		return c
+	})
+
+	page := domain.BreakdownPage{Groups: make([]domain.BreakdownGroup, 0, query.Top), Total: len(groups)}
+	for _, g := range pageOf(groups, query.Top, query.Offset) {
+		g.Values = make(map[string]any, len(query.Dimensions))
+		for _, dimension := range query.Dimensions {
+			g.Values[dimension] = inputDimension(g.values, dimension)
+		}
+		page.Groups = append(page.Groups, g.BreakdownGroup)
+	}
+
+	return page, nil
+}
+
+func (m *memoryFizzBuzzRepository) GetLimitHistogram(_ context.Context, query domain.LimitHistogramQuery) ([]domain.LimitBucket, errors.Error) {
+	rows := make(map[int]*limitBucketRow)
+	for _, r := range m.hitsWithin(query.Since, query.Until) {
+		// Without width, the key is the number of digits of the limit
+		key := len(strconv.Itoa(r.request.Limit))
+		if query.Width > 0 {
+			key = (r.request.Limit - 1) / query.Width
+		}
+
+		row, ok := rows[key]
+		if !ok {
+			row = &limitBucketRow{Key: key}
+			rows[key] = row
+		}
+		row.Hits += r.request.Hits
+		row.Requests++
+	}
+
+	buckets := make([]domain.LimitBucket, 0, len(rows))
+	for _, row := range rows {
+		buckets = append(buckets, limitBucket(*row, query.Width))
+	}
+	slices.SortFunc(buckets, func(a, b domain.LimitBucket) int { return cmp.Compare(a.Min, b.Min) })
+
+	return buckets, nil
+}
+
+func (m *memoryFizzBuzzRepository) GetTrending(_ context.Context, query domain.TrendingQuery) ([]domain.TrendingRequest, errors.Error) {
+	start := query.Until.Add(-query.Window)
+
+	type trending struct {
+		key requestKey
+		domain.TrendingRequest
+	}
+
+	m.mu.RLock()
+	var requests []trending
+	for _, stored := range m.requests {
+		r := trending{key: stored.key, TrendingRequest: domain.TrendingRequest{FizzBuzzInput: stored.request.FizzBuzzInput}}
+		for bucket, h := range stored.history {
+			if inWindow(bucket, start, query.Until) {
+				r.Hits += h
+			} else if inWindow(bucket, start.Add(-query.Window), start) {
+				r.PreviousHits += h
+			}
+		}
+
+		if r.Hits > 0 {
+			r.Growth = float64(r.Hits-r.PreviousHits) / float64(max(r.PreviousHits, 1))
+			requests = append(requests, r)
+		}
+	}
+	m.mu.RUnlock()
+
+	slices.SortFunc(requests, func(a, b trending) int {
+		return cmp.Or(cmp.Compare(b.Growth, a.Growth), cmp.Compare(b.Hits, a.Hits), a.key.compare(b.key))
+	})
+
+	result := make([]domain.TrendingRequest, 0, query.Top)
+	for _, r := range pageOf(requests, query.Top, 0) {
+		result = append(result, r.TrendingRequest)
+	}
+
+	return result, nil
+}
+
+// hitsWithin returns the requests with their hits within the [since, until) window, a zero time leaving it open.
+// Within a window, the first and last seen times are the starts of the first and last buckets with hits.
+func (m *memoryFizzBuzzRepository) hitsWithin(since, until time.Time) []keyedRequest {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	requests := make([]keyedRequest, 0, len(m.requests))
+	for _, stored := range m.requests {
+		if since.IsZero() && until.IsZero() {
+			requests = append(requests, keyedRequest{key: stored.key, request: stored.request})
+			continue
+		}
+
+		request := domain.FizzbuzzRequest{FizzBuzzInput: stored.request.FizzBuzzInput}
+		for bucket, h := range stored.history {
+			if !inWindow(bucket, since, until) {
+				continue
+			}
+
+			seen := time.Unix(bucket, 0).UTC()
+			if request.Hits == 0 || seen.Before(request.FirstSeen) {
+				request.FirstSeen = seen
+			}
+			if request.Hits == 0 || seen.After(request.LastSeen) {
+				request.LastSeen = seen
+			}
+			request.Hits += h
+		}
+
+		if request.Hits > 0 {
+			requests = append(requests, keyedRequest{key: stored.key, request: request})
+		}
+	}
+
+	return requests
+}
+
+// inWindow reports whether a bucket starts within the [since, until) window, a zero time leaving it open
+func inWindow(bucket int64, since, until time.Time) bool {
+	return (since.IsZero() || bucket >= since.Unix()) && (until.IsZero() || bucket < until.Unix())
+}
+
+// compareStatsSort compares requests on the column of a domain.StatsQuery sort, in ascending order
+func compareStatsSort(sort string, a, b domain.FizzbuzzRequest) int {
+	switch sort {
+	case domain.StatsSortLimit:
+		return cmp.Compare(a.Limit, b.Limit)
+	case domain.StatsSortFirstSeen:
+		return a.FirstSeen.Compare(b.FirstSeen)
+	case domain.StatsSortLastSeen:
+		return a.LastSeen.Compare(b.LastSeen)
+	default:
+		return cmp.Compare(a.Hits, b.Hits)
+	}
+}
+
+// dimensionsKey returns key with only the columns of the given dimensions
+func dimensionsKey(key requestKey, dimensions []string) requestKey {
+	var grouped requestKey
+	for _, dimension := range dimensions {
+		switch dimension {
+		case domain.DimensionInt1:
+			grouped.int1 = key.int1
+		case domain.DimensionInt2:
+			grouped.int2 = key.int2
+		case domain.DimensionLimit:
+			grouped.limit = key.limit
+		case domain.DimensionStr1:
+			grouped.str1 = key.str1
+		case domain.DimensionStr2:
+			grouped.str2 = key.str2
+		case domain.DimensionRules:
+			grouped.rules = key.rules
+		}
+	}
+
+	return grouped
+}
+
+// compareDimension compares keys on the column of a dimension
+func compareDimension(dimension string, a, b requestKey) int {
+	switch dimension {
+	case domain.DimensionInt1:
+		return cmp.Compare(a.int1, b.int1)
+	case domain.DimensionInt2:
+		return cmp.Compare(a.int2, b.int2)
+	case domain.DimensionLimit:
+		return cmp.Compare(a.limit, b.limit)
+	case domain.DimensionStr1:
+		return cmp.Compare(a.str1, b.str1)
+	case domain.DimensionStr2:
+		return cmp.Compare(a.str2, b.str2)
+	default:
+		return cmp.Compare(a.rules, b.rules)
+	}
+}
+
+// inputDimension returns the value of a dimension of input in its API representation
+func inputDimension(input domain.FizzBuzzInput, dimension string) any {
+	switch dimension {
+	case domain.DimensionInt1:
+		return input.Int1
+	case domain.DimensionInt2:
+		return input.Int2
+	case domain.DimensionLimit:
+		return input.Limit
+	case domain.DimensionStr1:
+		return input.Str1
+	case domain.DimensionStr2:
+		return input.Str2
+	default:
+		return input.Rules
+	}
+}
+
+// pageOf returns the page of items of size top starting at offset
+func pageOf[T any](items []T, top, offset int) []T {
+	if offset >= len(items) {
+		return nil
+	}
+
+	return items[offset:min(offset+top, len(items))]
+}
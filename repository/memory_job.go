@@ -0,0 +1,86 @@
+package repository
+
+import (
+	"context"
+	"lbc/fizzbuzz/domain"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/mwm-io/gapi/errors"
+)
+
+type memoryJobRepository struct {
+	mu   sync.RWMutex
+	jobs map[string]domain.FizzbuzzJob
+}
+
+// NewMemoryJobRepository returns a JobRepository keeping the jobs in memory, for tests and local runs
+func NewMemoryJobRepository() JobRepository {
+	return &memoryJobRepository{
+		jobs: make(map[string]domain.FizzbuzzJob),
+	}
+}
+
+func (j *memoryJobRepository) Create(_ context.Context, job domain.FizzbuzzJob) errors.Error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if _, ok := j.jobs[job.ID]; ok {
+		return errors.Err("internal_error", "job %s already exists", job.ID)
+	}
+	j.jobs[job.ID] = job
+
+	return nil
+}
+
+func (j *memoryJobRepository) Update(_ context.Context, job domain.FizzbuzzJob) errors.Error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if _, ok := j.jobs[job.ID]; ok {
+		j.jobs[job.ID] = job
+	}
+
+	return nil
+}
+
+func (j *memoryJobRepository) Get(_ context.Context, id string) (domain.FizzbuzzJob, errors.Error) {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+	job, ok := j.jobs[id]
+	if !ok {
+		return job, errors.NotFound("job_not_found", "job %s not found", id)
+	}
+
+	return job, nil
+}
+
+// ListUnfinished returns the queued and running jobs, oldest first
+func (j *memoryJobRepository) ListUnfinished(_ context.Context) ([]domain.FizzbuzzJob, errors.Error) {
+	j.mu.RLock()
+	var jobs []domain.FizzbuzzJob
+	for _, job := range j.jobs {
+		if job.Status == domain.JobStatusQueued || job.Status == domain.JobStatusRunning {
+			jobs = append(jobs, job)
+		}
+	}
+	j.mu.RUnlock()
+
+	slices.SortFunc(jobs, func(a, b domain.FizzbuzzJob) int { return a.CreatedAt.Compare(b.CreatedAt) })
+
+	return jobs, nil
+}
+
+// DeleteFinishedBefore deletes the jobs finished before the given time, returning their IDs
+func (j *memoryJobRepository) DeleteFinishedBefore(_ context.Context, before time.Time) ([]string, errors.Error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	var ids []string
+	for id, job := range j.jobs {
+		if job.FinishedAt != nil && job.FinishedAt.Before(before) {
+			ids = append(ids, id)
+			delete(j.jobs, id)
+		}
+	}
+
+	return ids, nil
+}
@@ -0,0 +1,188 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"lbc/fizzbuzz/domain"
+	"math"
+	"time"
+
+	"github.com/mwm-io/gapi/errors"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/schema"
+	"go.uber.org/zap"
+)
+
+// dimensionColumns maps the breakdown dimensions to their column
+var dimensionColumns = map[string]string{
+	domain.DimensionInt1:  "int1",
+	domain.DimensionInt2:  "int2",
+	domain.DimensionLimit: "max_limit",
+	domain.DimensionStr1:  "str1",
+	domain.DimensionStr2:  "str2",
+	domain.DimensionRules: "rules",
+}
+
+// limitBucketRow is a bucket of the limit histogram as scanned from the database
+type limitBucketRow struct {
+	Key      int `bun:"key"`
+	Hits     int `bun:"hits"`
+	Requests int `bun:"requests"`
+}
+
+// GetBreakdown returns a page of the hits grouped by the dimensions of query, the most hit groups first.
+// Groups with the same hits are ordered by their values.
+func (f *fizzBuzzRepository) GetBreakdown(ctx context.Context, query domain.BreakdownQuery) (domain.BreakdownPage, errors.Error) {
+	q := f.db.NewSelect().
+		TableExpr("? AS r", f.hitsSource(query.Since, query.Until))
+
+	columns := make([]string, len(query.Dimensions))
+	for i, dimension := range query.Dimensions {
+		columns[i] = dimensionColumns[dimension]
+		q = q.ColumnExpr("? AS ?", bun.Ident(columns[i]), bun.Ident(dimension))
+	}
+
+	var rows []map[string]any
+	total, err := q.
+		ColumnExpr("CAST(SUM(hits) AS BIGINT) AS hits").
+		ColumnExpr("COUNT(*) AS requests").
+		Group(columns...).
+		OrderExpr("hits DESC").
+		Order(columns...).
+		Limit(query.Top).
+		Offset(query.Offset).
+		ScanAndCount(ctx, &rows)
+	if err != nil {
+		f.logger.Error("Failed to get stats breakdown", zap.Error(err), zap.Strings("dimensions", query.Dimensions))
+		return domain.BreakdownPage{}, errors.Wrap(err).WithKind("internal_error")
+	}
+
+	groups := make([]domain.BreakdownGroup, len(rows))
+	for i, row := range rows {
+		groups[i] = domain.BreakdownGroup{
+			Values:   make(map[string]any, len(query.Dimensions)),
+			Hits:     intValue(row["hits"]),
+			Requests: intValue(row["requests"]),
+		}
+		for _, dimension := range query.Dimensions {
+			groups[i].Values[dimension] = dimensionValue(dimension, row[dimension])
+		}
+	}
+
+	return domain.BreakdownPage{Groups: groups, Total: total}, nil
+}
+
+// GetLimitHistogram returns the hits grouped by ranges of limits, omitting empty ranges
+func (f *fizzBuzzRepository) GetLimitHistogram(ctx context.Context, query domain.LimitHistogramQuery) ([]domain.LimitBucket, errors.Error) {
+	// Without width, the key is the number of digits of the limit
+	key := bun.SafeQuery("length(CAST(max_limit AS TEXT))")
+	if query.Width > 0 {
+		key = bun.SafeQuery("(max_limit - 1) / ?", query.Width)
+	}
+
+	var rows []limitBucketRow
+	err := f.db.NewSelect().
+		TableExpr("? AS r", f.hitsSource(query.Since, query.Until)).
+		ColumnExpr("? AS key", key).
+		ColumnExpr("CAST(SUM(hits) AS BIGINT) AS hits").
+		ColumnExpr("COUNT(*) AS requests").
+		GroupExpr("key").
+		OrderExpr("key ASC").
+		Scan(ctx, &rows)
+	if err != nil {
+		f.logger.Error("Failed to get limit histogram", zap.Error(err))
+		return nil, errors.Wrap(err).WithKind("internal_error")
+	}
+
+	buckets := make([]domain.LimitBucket, len(rows))
+	for i, row := range rows {
+		buckets[i] = limitBucket(row, query.Width)
+	}
+
+	return buckets, nil
+}
+
+// limitBucket converts the key of a bucket of the limit histogram to its range of limits
+func limitBucket(row limitBucketRow, width int) domain.LimitBucket {
+	bucket := domain.LimitBucket{Hits: row.Hits, Requests: row.Requests}
+	if width > 0 {
+		bucket.Min, bucket.Max = row.Key*width+1, (row.Key+1)*width
+	} else {
+		bucket.Min, bucket.Max = int(math.Pow10(row.Key-1)), int(math.Pow10(row.Key))-1
+	}
+
+	return bucket
+}
+
+// hitsSource returns the hits per request, summed from the history when restricted to a [since, until) window.
+// Hits summed over this source are cast back to BIGINT, as the sum of a sum is a NUMERIC in PostgreSQL.
+func (f *fizzBuzzRepository) hitsSource(since, until time.Time) schema.QueryAppender {
+	if since.IsZero() && until.IsZero() {
+		return bun.SafeQuery("(?)", f.requestTotals())
+	}
+
+	return bun.SafeQuery("(?)", f.db.NewSelect().
+		Model((*requestHits)(nil)).
+		Column(requestColumns...).
+		ColumnExpr("SUM(hits) AS hits").
+		Apply(windowFilter(since, until)).
+		Group(requestColumns...))
+}
+
+// intValue converts an integer scanned into an interface
+func intValue(v any) int {
+	i, _ := v.(int64)
+	return int(i)
+}
+
+// dimensionValue converts the value of a dimension scanned into an interface to its API representation
+func dimensionValue(dimension string, v any) any {
+	if b, ok := v.([]byte); ok {
+		v = string(b)
+	}
+
+	switch dimension {
+	case domain.DimensionRules:
+		var rules domain.Rules
+		s, _ := v.(string)
+		_ = json.Unmarshal([]byte(s), &rules)
+		return rules
+	case domain.DimensionStr1, domain.DimensionStr2:
+		return v
+	default:
+		return intValue(v)
+	}
+}
+
+// GetTrending returns the requests with hits within the window of query, ranked by growth then hits.
+// Both windows are read from the history with a single range scan of the bucket index.
+func (f *fizzBuzzRepository) GetTrending(ctx context.Context, query domain.TrendingQuery) ([]domain.TrendingRequest, errors.Error) {
+	start := query.Until.Add(-query.Window)
+
+	windows := f.db.NewSelect().
+		Model((*requestHits)(nil)).
+		Column(requestColumns...).
+		ColumnExpr("SUM(CASE WHEN bucket >= ? THEN hits ELSE 0 END) AS hits", start.Unix()).
+		ColumnExpr("SUM(CASE WHEN bucket < ? THEN hits ELSE 0 END) AS previous_hits", start.Unix()).
+		Apply(windowFilter(start.Add(-query.Window), query.Until)).
+		Group(requestColumns...)
+
+	requests := make([]domain.TrendingRequest, 0, query.Top)
+	err := f.db.NewSelect().
+		TableExpr("(?) AS windows", windows).
+		ColumnExpr("*").
+		ColumnExpr("CAST(hits - previous_hits AS DOUBLE PRECISION) / "+
+			"CASE WHEN previous_hits > 0 THEN previous_hits ELSE 1 END AS growth").
+		Where("hits > 0").
+		OrderExpr("growth DESC").
+		OrderExpr("hits DESC").
+		Order(tieBreakOrder...).
+		Limit(query.Top).
+		Scan(ctx, &requests)
+	if err != nil {
+		f.logger.Error("Failed to get trending FizzBuzzRequests", zap.Error(err))
+		return nil, errors.Wrap(err).WithKind("internal_error")
+	}
+
+	return requests, nil
+}
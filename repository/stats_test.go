@@ -0,0 +1,158 @@
+package repository
+
+import (
+	"context"
+	"lbc/fizzbuzz/domain"
+	"lbc/fizzbuzz/internal"
+	"lbc/fizzbuzz/testdata/utils"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestFizzBuzzRepositoryGetBreakdown(t *testing.T) {
+	db := internal.Clients.PostgreSQL()
+	repo := NewFizzBuzzRepository(db, zap.NewExample())
+
+	err := utils.ResetDatabase(db)
+	assert.Nil(t, err)
+
+	rules := domain.Rules{{Divisor: 3, Word: "fizz"}, {Divisor: 5, Word: "buzz"}}
+	err = repo.SaveBatch(context.Background(), []domain.FizzBuzzInput{
+		{Int1: 3, Int2: 5, Limit: 100, Str1: "fizz", Str2: "buzz"},
+		{Int1: 3, Int2: 5, Limit: 15, Str1: "fizz", Str2: "buzz"},
+		{Int1: 3, Int2: 5, Limit: 15, Str1: "fizz", Str2: "buzz"},
+		{Int1: 2, Int2: 7, Limit: 50, Str1: "foo", Str2: "bar"},
+		{Limit: 15, Rules: rules},
+	})
+	assert.Nil(t, err)
+
+	tests := []struct {
+		name     string
+		query    domain.BreakdownQuery
+		expected domain.BreakdownPage
+	}{
+		{
+			name:  "By word pair",
+			query: domain.BreakdownQuery{Dimensions: []string{domain.DimensionStr1, domain.DimensionStr2}, Top: 10},
+			expected: domain.BreakdownPage{Total: 3, Groups: []domain.BreakdownGroup{
+				{Values: map[string]any{"str1": "fizz", "str2": "buzz"}, Hits: 3, Requests: 2},
+				{Values: map[string]any{"str1": "", "str2": ""}, Hits: 1, Requests: 1},
+				{Values: map[string]any{"str1": "foo", "str2": "bar"}, Hits: 1, Requests: 1},
+			}},
+		},
+		{
+			name:  "By limit and rules",
+			query: domain.BreakdownQuery{Dimensions: []string{domain.DimensionLimit, domain.DimensionRules}, Top: 1, Offset: 1},
+			expected: domain.BreakdownPage{Total: 4, Groups: []domain.BreakdownGroup{
+				{Values: map[string]any{"limit": 15, "rules": rules}, Hits: 1, Requests: 1},
+			}},
+		},
+		{
+			name:     "Empty time window",
+			query:    domain.BreakdownQuery{Dimensions: []string{domain.DimensionInt1}, Top: 10, Until: time.Now().Add(-time.Hour)},
+			expected: domain.BreakdownPage{Total: 0, Groups: []domain.BreakdownGroup{}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, err := repo.GetBreakdown(context.Background(), tt.query)
+			assert.Nil(t, err)
+			assert.Equal(t, tt.expected, page)
+		})
+	}
+}
+
+func TestFizzBuzzRepositoryGetLimitHistogram(t *testing.T) {
+	db := internal.Clients.PostgreSQL()
+	repo := NewFizzBuzzRepository(db, zap.NewExample())
+
+	err := utils.ResetDatabase(db)
+	assert.Nil(t, err)
+
+	err = repo.SaveBatch(context.Background(), []domain.FizzBuzzInput{
+		{Int1: 3, Int2: 5, Limit: 1, Str1: "fizz", Str2: "buzz"},
+		{Int1: 3, Int2: 5, Limit: 9, Str1: "fizz", Str2: "buzz"},
+		{Int1: 3, Int2: 5, Limit: 10, Str1: "fizz", Str2: "buzz"},
+		{Int1: 3, Int2: 5, Limit: 10, Str1: "fizz", Str2: "buzz"},
+		{Int1: 3, Int2: 5, Limit: 1000, Str1: "fizz", Str2: "buzz"},
+	})
+	assert.Nil(t, err)
+
+	tests := []struct {
+		name     string
+		query    domain.LimitHistogramQuery
+		expected []domain.LimitBucket
+	}{
+		{
+			name:  "Powers of ten",
+			query: domain.LimitHistogramQuery{},
+			expected: []domain.LimitBucket{
+				{Min: 1, Max: 9, Hits: 2, Requests: 2},
+				{Min: 10, Max: 99, Hits: 2, Requests: 1},
+				{Min: 1000, Max: 9999, Hits: 1, Requests: 1},
+			},
+		},
+		{
+			name:  "Width",
+			query: domain.LimitHistogramQuery{Width: 10, Since: time.Now().Add(-time.Hour)},
+			expected: []domain.LimitBucket{
+				{Min: 1, Max: 10, Hits: 4, Requests: 3},
+				{Min: 991, Max: 1000, Hits: 1, Requests: 1},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buckets, err := repo.GetLimitHistogram(context.Background(), tt.query)
+			assert.Nil(t, err)
+			assert.Equal(t, tt.expected, buckets)
+		})
+	}
+}
+
+func TestFizzBuzzRepositoryGetTrending(t *testing.T) {
+	db := internal.Clients.PostgreSQL()
+	repo := NewFizzBuzzRepository(db, zap.NewExample())
+
+	err := utils.ResetDatabase(db)
+	assert.Nil(t, err)
+
+	// Rules are scanned back as an empty list
+	fizzBuzz := domain.FizzBuzzInput{Int1: 3, Int2: 5, Limit: 100, Str1: "fizz", Str2: "buzz", Rules: domain.Rules{}}
+	fooBar := domain.FizzBuzzInput{Int1: 2, Int2: 7, Limit: 50, Str1: "foo", Str2: "bar", Rules: domain.Rules{}}
+	johnDoe := domain.FizzBuzzInput{Int1: 5, Int2: 8, Limit: 50, Str1: "john", Str2: "doe", Rules: domain.Rules{}}
+	stale := domain.FizzBuzzInput{Int1: 4, Int2: 6, Limit: 10, Str1: "old", Str2: "news", Rules: domain.Rules{}}
+
+	// Windows of an hour ending at 12:00, 1704110400 being 2024-01-01T12:00:00Z
+	until := time.Unix(1704110400, 0)
+	current, previous, older := until.Add(-time.Minute).Unix(), until.Add(-90*time.Minute).Unix(), until.Add(-3*time.Hour).Unix()
+	history := []requestHits{
+		{FizzBuzzInput: fizzBuzz, Bucket: previous, Hits: 100},
+		{FizzBuzzInput: fizzBuzz, Bucket: current, Hits: 150},
+		{FizzBuzzInput: fooBar, Bucket: current, Hits: 5},
+		{FizzBuzzInput: johnDoe, Bucket: previous, Hits: 1},
+		{FizzBuzzInput: johnDoe, Bucket: current, Hits: 3},
+		{FizzBuzzInput: stale, Bucket: previous, Hits: 10},
+		{FizzBuzzInput: stale, Bucket: older, Hits: 10},
+		{FizzBuzzInput: fizzBuzz, Bucket: until.Unix(), Hits: 1000},
+	}
+	_, sqlErr := db.NewInsert().Model(&history).Exec(context.Background())
+	assert.NoError(t, sqlErr)
+
+	requests, err := repo.GetTrending(context.Background(), domain.TrendingQuery{Window: time.Hour, Top: 10, Until: until})
+	assert.Nil(t, err)
+	assert.Equal(t, []domain.TrendingRequest{
+		{FizzBuzzInput: fooBar, Hits: 5, PreviousHits: 0, Growth: 5},
+		{FizzBuzzInput: johnDoe, Hits: 3, PreviousHits: 1, Growth: 2},
+		{FizzBuzzInput: fizzBuzz, Hits: 150, PreviousHits: 100, Growth: 0.5},
+	}, requests)
+
+	requests, err = repo.GetTrending(context.Background(), domain.TrendingQuery{Window: time.Hour, Top: 1, Until: until})
+	assert.Nil(t, err)
+	assert.Len(t, requests, 1)
+}
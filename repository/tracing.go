@@ -0,0 +1,8 @@
+package repository
+
+import (
+	"go.opentelemetry.io/otel"
+)
+
+// tracer starts the spans of the repositories not covered by the query hooks of the databases
+var tracer = otel.Tracer("lbc/fizzbuzz/repository")
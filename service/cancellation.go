@@ -0,0 +1,43 @@
+package service
+
+import (
+	"context"
+	stderrors "errors"
+	"net/http"
+
+	"github.com/mwm-io/gapi/errors"
+)
+
+// StatusClientClosedRequest is the non-standard status of the requests cancelled by their client, as logged by nginx.
+// The client being gone, it only shows in logs and metrics.
+const StatusClientClosedRequest = 499
+
+// contextError returns the error of a request whose ctx is done, nil otherwise.
+// Requests running past their deadline get the request_timeout kind and the others, cancelled by their client
+// or the server stopping, the request_cancelled kind, so that neither is mistaken for an internal error.
+func contextError(ctx context.Context) errors.Error {
+	err := ctx.Err()
+	switch {
+	case err == nil:
+		return nil
+	case stderrors.Is(err, context.DeadlineExceeded):
+		return errors.Wrap(err).WithKind("request_timeout").WithStatus(http.StatusServiceUnavailable).
+			WithMessage("the request did not complete within its deadline")
+	default:
+		return errors.Wrap(err).WithKind("request_cancelled").WithStatus(StatusClientClosedRequest).
+			WithMessage("the request was cancelled")
+	}
+}
+
+// readError returns the error of ctx when a read failed because ctx is done, err otherwise,
+// so that the database calls abandoned by a request are not reported as internal errors
+func readError(ctx context.Context, err errors.Error) errors.Error {
+	if err == nil {
+		return nil
+	}
+	if ctxErr := contextError(ctx); ctxErr != nil {
+		return ctxErr
+	}
+
+	return err
+}
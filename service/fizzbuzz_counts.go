@@ -0,0 +1,124 @@
+package service
+
+import (
+	"context"
+	"lbc/fizzbuzz/domain"
+	"lbc/fizzbuzz/internal"
+	"math/bits"
+	"strings"
+
+	"github.com/mwm-io/gapi/errors"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// CountFizzBuzz counts the terms of each category within the window of the input, without generating them.
+// The request is not recorded in the stats.
+func (f *fizzBuzzService) CountFizzBuzz(ctx context.Context, input domain.FizzBuzzInput) (_ domain.FizzBuzzCounts, gErr errors.Error) {
+	_, span := tracer.Start(ctx, "FizzBuzzService.CountFizzBuzz")
+	defer func() { internal.EndSpan(span, gErr) }()
+	span.SetAttributes(attribute.Int("fizzbuzz.limit", input.Limit))
+
+	if err := input.ValidateUnbounded(); err != nil {
+		return domain.FizzBuzzCounts{}, errors.Wrap(err).WithKind("invalid_input")
+	}
+
+	rules := input.RuleSet()
+	first, last := input.Window()
+	upToLast := exactCounts(rules, uint64(last))
+	beforeFirst := exactCounts(rules, uint64(first-1))
+
+	counts := domain.FizzBuzzCounts{
+		First:        first,
+		Last:         last,
+		Plain:        int(upToLast[0] - beforeFirst[0]),
+		Combinations: []domain.CombinationCount{},
+	}
+	for mask := 1; mask < len(upToLast); mask++ {
+		count := int(upToLast[mask] - beforeFirst[mask])
+		if count == 0 {
+			continue
+		}
+
+		var indexes []int
+		var words []string
+		for i, rule := range rules {
+			if mask&(1<<i) != 0 {
+				indexes = append(indexes, i)
+				words = append(words, rule.Word)
+			}
+		}
+		counts.Combinations = append(counts.Combinations, domain.CombinationCount{
+			Rules: indexes,
+			Words: words,
+			Value: strings.Join(words, ""),
+			Count: count,
+		})
+	}
+
+	return counts, nil
+}
+
+// exactCounts returns, for every subset of rules given as a bit mask, how many integers in 1..limit
+// are divisible by the divisors of exactly this subset. Index 0 counts the plain numbers.
+//
+// The number of multiples of a whole subset is limit/lcm(subset), gcd and lcm take care of divisors
+// sharing factors. Inclusion–exclusion over supersets then turns these into exact counts,
+// in O(len(rules) * 2^len(rules)) whatever the limit.
+func exactCounts(rules domain.Rules, limit uint64) []uint64 {
+	size := 1 << len(rules)
+
+	// lcms[mask] is the lcm of the divisors of mask, or 0 when it exceeds limit
+	lcms := make([]uint64, size)
+	counts := make([]uint64, size)
+	lcms[0] = 1
+	counts[0] = limit
+	for mask := 1; mask < size; mask++ {
+		low := bits.TrailingZeros(uint(mask))
+		lcms[mask] = boundedLcm(lcms[mask&(mask-1)], absDivisor(rules[low].Divisor), limit)
+		if lcms[mask] != 0 {
+			counts[mask] = limit / lcms[mask]
+		}
+	}
+
+	for i := range rules {
+		bit := 1 << i
+		for mask := 0; mask < size; mask++ {
+			if mask&bit == 0 {
+				counts[mask] -= counts[mask|bit]
+			}
+		}
+	}
+
+	return counts
+}
+
+// boundedLcm returns lcm(a, b), or 0 when a is 0 or the lcm exceeds limit
+func boundedLcm(a, b, limit uint64) uint64 {
+	if a == 0 {
+		return 0
+	}
+
+	x := a / gcd(a, b)
+	if x > limit/b {
+		return 0
+	}
+
+	return x * b
+}
+
+func gcd(a, b uint64) uint64 {
+	for b != 0 {
+		a, b = b, a%b
+	}
+
+	return a
+}
+
+// absDivisor returns |d|, without overflowing on math.MinInt
+func absDivisor(d int) uint64 {
+	if d < 0 {
+		return uint64(-(d + 1)) + 1
+	}
+
+	return uint64(d)
+}
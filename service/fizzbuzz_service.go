@@ -3,15 +3,30 @@ package service
 import (
 	"context"
 	"lbc/fizzbuzz/domain"
+	"lbc/fizzbuzz/internal"
 	"lbc/fizzbuzz/repository"
 	"strconv"
 	"strings"
 
 	"github.com/mwm-io/gapi/errors"
+	"go.opentelemetry.io/otel/attribute"
 )
 
+// cancellationCheckInterval is the number of terms generated between two context checks
+const cancellationCheckInterval = 1024
+
 type FizzBuzzService interface {
-	GenerateFizzBuzz(input domain.FizzBuzzInput) (string, errors.Error)
+	GenerateFizzBuzz(ctx context.Context, input domain.FizzBuzzInput) (string, errors.Error)
+	StreamFizzBuzz(ctx context.Context, input domain.FizzBuzzInput, yield func(domain.Term) error) errors.Error
+	GetTerm(ctx context.Context, input domain.FizzBuzzInput, n int) (domain.Term, errors.Error)
+	CountFizzBuzz(ctx context.Context, input domain.FizzBuzzInput) (domain.FizzBuzzCounts, errors.Error)
+	GenerateFizzBuzzBatch(ctx context.Context, inputs []domain.FizzBuzzInput) ([]BatchResult, errors.Error)
+}
+
+// BatchResult is the outcome of a single item of a batch, either a result or an error
+type BatchResult struct {
+	Result string
+	Err    errors.Error
 }
 
 type fizzBuzzService struct {
@@ -24,29 +39,178 @@ func NewFizzBuzzService(fizzBuzzRepository repository.FizzBuzzRepository) FizzBu
 	}
 }
 
-func (f *fizzBuzzService) GenerateFizzBuzz(input domain.FizzBuzzInput) (string, errors.Error) {
+func (f *fizzBuzzService) GenerateFizzBuzz(ctx context.Context, input domain.FizzBuzzInput) (result string, gErr errors.Error) {
+	ctx, span := tracer.Start(ctx, "FizzBuzzService.GenerateFizzBuzz")
+	defer func() { internal.EndSpan(span, gErr) }()
+	span.SetAttributes(attribute.Int("fizzbuzz.limit", input.Limit))
+
 	if err := input.Validate(); err != nil {
 		return "", errors.Wrap(err).WithKind("invalid_input")
 	}
 
-	var result []string
-	for i := 1; i <= input.Limit; i++ {
-		switch {
-		// Note: If Int1 and Int2 share factors, i%(Int1*Int2) == 0 won't work
-		case i%input.Int1 == 0 && i%input.Int2 == 0:
-			result = append(result, input.Str1+input.Str2)
-		case i%input.Int1 == 0:
-			result = append(result, input.Str1)
-		case i%input.Int2 == 0:
-			result = append(result, input.Str2)
-		default:
-			result = append(result, strconv.Itoa(i))
+	result, terms, gErr := generate(ctx, input)
+	observeGeneration(generationModeSequence, input.Limit, terms)
+	span.SetAttributes(attribute.Int("fizzbuzz.terms", terms))
+	if gErr != nil {
+		return "", gErr
+	}
+
+	if err := f.fizzBuzzRepository.Save(ctx, input); err != nil {
+		return "", saveError(ctx, err)
+	}
+
+	return result, nil
+}
+
+// GenerateFizzBuzzBatch generates the sequence of every input, in the same order.
+// Invalid inputs get their own error, and all valid inputs are recorded in a single repository call.
+func (f *fizzBuzzService) GenerateFizzBuzzBatch(ctx context.Context, inputs []domain.FizzBuzzInput) (_ []BatchResult, gErr errors.Error) {
+	ctx, span := tracer.Start(ctx, "FizzBuzzService.GenerateFizzBuzzBatch")
+	defer func() { internal.EndSpan(span, gErr) }()
+	span.SetAttributes(attribute.Int("fizzbuzz.batch_size", len(inputs)))
+
+	results := make([]BatchResult, len(inputs))
+	valid := make([]domain.FizzBuzzInput, 0, len(inputs))
+	for i, input := range inputs {
+		if err := input.Validate(); err != nil {
+			results[i].Err = errors.Wrap(err).WithKind("invalid_input")
+			continue
 		}
+
+		var terms int
+		var gErr errors.Error
+		results[i].Result, terms, gErr = generate(ctx, input)
+		observeGeneration(generationModeBatch, input.Limit, terms)
+		if gErr != nil {
+			// The whole batch is abandoned, the following items being bound to the same context
+			return nil, gErr
+		}
+		valid = append(valid, input)
 	}
 
-	if err := f.fizzBuzzRepository.Save(context.Background(), input); err != nil {
-		return "", errors.Wrap(err).WithKind("internal_error")
+	if len(valid) > 0 {
+		if err := f.fizzBuzzRepository.SaveBatch(ctx, valid); err != nil {
+			return nil, saveError(ctx, err)
+		}
 	}
 
-	return strings.Join(result, ","), nil
+	return results, nil
+}
+
+// generate returns the comma-joined terms of the window of a validated input, and their number.
+// It stops when ctx is done, returning the number of terms generated until then.
+func generate(ctx context.Context, input domain.FizzBuzzInput) (string, int, errors.Error) {
+	rules := input.RuleSet()
+	first, last := input.Window()
+	var result strings.Builder
+	terms := 0
+	for i := first; i <= last; i++ {
+		if i%cancellationCheckInterval == 0 {
+			if err := contextError(ctx); err != nil {
+				return "", terms, err
+			}
+		}
+		if terms > 0 {
+			result.WriteByte(',')
+		}
+		result.WriteString(term(rules, i).Value)
+		terms++
+	}
+
+	return result.String(), terms, nil
+}
+
+// saveError returns the error of a failed repository call, telling a call interrupted by ctx apart from an internal error
+func saveError(ctx context.Context, err error) errors.Error {
+	if ctxErr := contextError(ctx); ctxErr != nil {
+		return ctxErr
+	}
+
+	return errors.Wrap(err).WithKind("internal_error")
+}
+
+// StreamFizzBuzz hands each term to yield as soon as it is produced, so memory usage does not depend on the limit,
+// then records the request. Like GenerateFizzBuzz, only the requests whose every term was produced are recorded.
+// Generation stops when ctx is done or when yield returns an error.
+func (f *fizzBuzzService) StreamFizzBuzz(ctx context.Context, input domain.FizzBuzzInput, yield func(domain.Term) error) (gErr errors.Error) {
+	ctx, span := tracer.Start(ctx, "FizzBuzzService.StreamFizzBuzz")
+	defer func() { internal.EndSpan(span, gErr) }()
+	span.SetAttributes(attribute.Int("fizzbuzz.limit", input.Limit))
+
+	if err := input.Validate(); err != nil {
+		return errors.Wrap(err).WithKind("invalid_input")
+	}
+
+	streamed, gErr := streamTerms(ctx, input, yield)
+	span.SetAttributes(attribute.Int("fizzbuzz.terms", streamed))
+	if gErr != nil {
+		return gErr
+	}
+
+	if err := f.fizzBuzzRepository.Save(ctx, input); err != nil {
+		return saveError(ctx, err)
+	}
+
+	return nil
+}
+
+// streamTerms hands each term of the window of a validated input to yield, without recording the request,
+// and returns the number of terms handed, including those of an interrupted stream.
+// Generation stops when ctx is done or when yield returns an error.
+func streamTerms(ctx context.Context, input domain.FizzBuzzInput, yield func(domain.Term) error) (int, errors.Error) {
+	rules := input.RuleSet()
+	first, last := input.Window()
+	streamed := 0
+	defer func() { observeGeneration(generationModeStream, input.Limit, streamed) }()
+	for i := first; i <= last; i++ {
+		if i%cancellationCheckInterval == 0 {
+			if err := contextError(ctx); err != nil {
+				return streamed, err
+			}
+		}
+
+		if err := yield(term(rules, i)); err != nil {
+			return streamed, errors.Wrap(err).WithKind("stream_error")
+		}
+		streamed++
+	}
+
+	return streamed, nil
+}
+
+// GetTerm evaluates the term at position n directly, without generating the sequence.
+// The limit and window of the input are ignored and the request is not recorded in the stats.
+func (f *fizzBuzzService) GetTerm(_ context.Context, input domain.FizzBuzzInput, n int) (domain.Term, errors.Error) {
+	if err := input.ValidateRules(); err != nil {
+		return domain.Term{}, errors.Wrap(err).WithKind("invalid_input")
+	}
+
+	if n <= 0 {
+		return domain.Term{}, errors.BadRequest("invalid_input", "n must be greater than 0")
+	}
+
+	return term(input.RuleSet(), n), nil
+}
+
+// term returns the FizzBuzz term for i: the words of every rule dividing i,
+// concatenated in declared order, or i itself when no rule applies.
+// Note: overlaps are detected rule by rule, so divisors sharing factors need no special case
+func term(rules domain.Rules, i int) domain.Term {
+	var sb strings.Builder
+	matches := 0
+	for _, rule := range rules {
+		if i%rule.Divisor == 0 {
+			sb.WriteString(rule.Word)
+			matches++
+		}
+	}
+
+	switch matches {
+	case 0:
+		return domain.Term{N: i, Value: strconv.Itoa(i), Kind: domain.TermKindNumber}
+	case 1:
+		return domain.Term{N: i, Value: sb.String(), Kind: domain.TermKindWord}
+	default:
+		return domain.Term{N: i, Value: sb.String(), Kind: domain.TermKindCombined}
+	}
 }
@@ -1,16 +1,20 @@
 package service_test
 
 import (
+	"context"
+	"errors"
 	"lbc/fizzbuzz/domain"
-	"lbc/fizzbuzz/internal"
 	"lbc/fizzbuzz/repository"
 	"lbc/fizzbuzz/service"
+	"math"
+	"net/http"
 	"strings"
 	"testing"
+	"time"
 
+	gapierrors "github.com/mwm-io/gapi/errors"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
-	"go.uber.org/zap"
 )
 
 // TestGenerateFizzBuzz /
@@ -56,6 +60,29 @@ func TestGenerateFizzBuzz(t *testing.T) {
 			expected:  "1,2,fizz,4,buzz,fizz,7,8,fizz,buzz,11,fizz,13,14,fizzbuzz",
 			expectErr: false,
 		},
+		{
+			name: "Three rules",
+			input: domain.FizzBuzzInput{Limit: 15, Rules: domain.Rules{
+				{Divisor: 3, Word: "fizz"}, {Divisor: 5, Word: "buzz"}, {Divisor: 7, Word: "bazz"},
+			}},
+			expected:  "1,2,fizz,4,buzz,fizz,bazz,8,fizz,buzz,11,fizz,13,bazz,fizzbuzz",
+			expectErr: false,
+		},
+		{
+			name: "Rules applied in declared order",
+			input: domain.FizzBuzzInput{Limit: 6, Rules: domain.Rules{
+				{Divisor: 3, Word: "bar"}, {Divisor: 2, Word: "foo"},
+			}},
+			expected:  "1,foo,bar,foo,5,barfoo",
+			expectErr: false,
+		},
+		{
+			name: "Duplicate rule divisors",
+			input: domain.FizzBuzzInput{Limit: 6, Rules: domain.Rules{
+				{Divisor: 3, Word: "fizz"}, {Divisor: 3, Word: "buzz"},
+			}},
+			expectErr: true,
+		},
 		{
 			name:      "Empty strings",
 			input:     domain.FizzBuzzInput{Int1: 3, Int2: 5, Limit: 15, Str1: "", Str2: "buzz"},
@@ -81,9 +108,9 @@ func TestGenerateFizzBuzz(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			repo := repository.NewFizzBuzzRepository(internal.Clients.PostgreSQL(), zap.NewExample())
+			repo := repository.NewMemoryFizzBuzzRepository()
 			svc := service.NewFizzBuzzService(repo)
-			result, err := svc.GenerateFizzBuzz(tt.input)
+			result, err := svc.GenerateFizzBuzz(context.Background(), tt.input)
 
 			if tt.expectErr {
 				require.Error(t, err)
@@ -97,7 +124,7 @@ func TestGenerateFizzBuzz(t *testing.T) {
 
 // TestGenerateFizzBuzz_LargeLimit /
 func TestGenerateFizzBuzz_LargeLimit(t *testing.T) {
-	repo := repository.NewFizzBuzzRepository(internal.Clients.PostgreSQL(), zap.NewExample())
+	repo := repository.NewMemoryFizzBuzzRepository()
 	svc := service.NewFizzBuzzService(repo)
 	tests := []struct {
 		name          string
@@ -157,7 +184,7 @@ func TestGenerateFizzBuzz_LargeLimit(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result, err := svc.GenerateFizzBuzz(tt.input)
+			result, err := svc.GenerateFizzBuzz(context.Background(), tt.input)
 			require.NoError(t, err)
 			resultSlice := strings.Split(result, ",")
 			assert.Equal(t, tt.expectedStart, strings.Join(resultSlice[:10], ","))
@@ -165,3 +192,283 @@ func TestGenerateFizzBuzz_LargeLimit(t *testing.T) {
 		})
 	}
 }
+
+// TestStreamFizzBuzz /
+func TestStreamFizzBuzz(t *testing.T) {
+	repo := repository.NewMemoryFizzBuzzRepository()
+	svc := service.NewFizzBuzzService(repo)
+	input := domain.FizzBuzzInput{Int1: 3, Int2: 5, Limit: 15, Str1: "fizz", Str2: "buzz"}
+
+	t.Run("Yields every term in order", func(t *testing.T) {
+		var terms []string
+		err := svc.StreamFizzBuzz(context.Background(), input, func(term domain.Term) error {
+			assert.Equal(t, len(terms)+1, term.N)
+			terms = append(terms, term.Value)
+			return nil
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "1,2,fizz,4,buzz,fizz,7,8,fizz,buzz,11,fizz,13,14,fizzbuzz", strings.Join(terms, ","))
+
+		mostHits, err := repo.GetMostHits(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, 1, mostHits.Hits)
+	})
+
+	t.Run("Stops when yield fails", func(t *testing.T) {
+		var terms []string
+		err := svc.StreamFizzBuzz(context.Background(), input, func(term domain.Term) error {
+			if term.N > 4 {
+				return errors.New("client gone")
+			}
+			terms = append(terms, term.Value)
+			return nil
+		})
+		require.Error(t, err)
+		assert.Equal(t, "1,2,fizz,4", strings.Join(terms, ","))
+
+		// An interrupted stream is not recorded
+		mostHits, err := repo.GetMostHits(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, 1, mostHits.Hits)
+	})
+
+	t.Run("Invalid input", func(t *testing.T) {
+		err := svc.StreamFizzBuzz(context.Background(), domain.FizzBuzzInput{Int1: 3, Int2: 5}, func(domain.Term) error {
+			t.Fatal("no term expected")
+			return nil
+		})
+		require.Error(t, err)
+		assert.Equal(t, "invalid_input", err.Kind())
+	})
+}
+
+// TestGetTerm /
+func TestGetTerm(t *testing.T) {
+	repo := repository.NewMemoryFizzBuzzRepository()
+	svc := service.NewFizzBuzzService(repo)
+	tests := []struct {
+		name      string
+		input     domain.FizzBuzzInput
+		n         int
+		expected  string
+		kind      string
+		expectErr bool
+	}{
+		{
+			name:     "Plain number",
+			input:    domain.FizzBuzzInput{Int1: 3, Int2: 5, Str1: "fizz", Str2: "buzz"},
+			n:        7,
+			expected: "7",
+			kind:     domain.TermKindNumber,
+		},
+		{
+			name:     "Both words",
+			input:    domain.FizzBuzzInput{Int1: 3, Int2: 5, Str1: "fizz", Str2: "buzz"},
+			n:        15,
+			expected: "fizzbuzz",
+			kind:     domain.TermKindCombined,
+		},
+		{
+			name:     "Far beyond any generation limit",
+			input:    domain.FizzBuzzInput{Int1: 3, Int2: 5, Str1: "fizz", Str2: "buzz"},
+			n:        987654321,
+			expected: "fizz",
+			kind:     domain.TermKindWord,
+		},
+		{
+			name:     "Near the maximum position",
+			input:    domain.FizzBuzzInput{Int1: 3, Int2: 5, Str1: "fizz", Str2: "buzz"},
+			n:        9223372036854775805,
+			expected: "buzz",
+			kind:     domain.TermKindWord,
+		},
+		{
+			name: "Rule list",
+			input: domain.FizzBuzzInput{Rules: domain.Rules{
+				{Divisor: 3, Word: "fizz"}, {Divisor: 5, Word: "buzz"}, {Divisor: 7, Word: "bazz"},
+			}},
+			n:        105,
+			expected: "fizzbuzzbazz",
+			kind:     domain.TermKindCombined,
+		},
+		{
+			name:      "Zero position",
+			input:     domain.FizzBuzzInput{Int1: 3, Int2: 5, Str1: "fizz", Str2: "buzz"},
+			n:         0,
+			expectErr: true,
+		},
+		{
+			name:      "Invalid rules",
+			input:     domain.FizzBuzzInput{Int1: 3, Int2: 3, Str1: "fizz", Str2: "buzz"},
+			n:         3,
+			expectErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := svc.GetTerm(context.Background(), tt.input, tt.n)
+
+			if tt.expectErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, domain.Term{N: tt.n, Value: tt.expected, Kind: tt.kind}, result)
+			}
+		})
+	}
+}
+
+// TestCountFizzBuzz /
+func TestCountFizzBuzz(t *testing.T) {
+	repo := repository.NewMemoryFizzBuzzRepository()
+	svc := service.NewFizzBuzzService(repo)
+	tests := []struct {
+		name      string
+		input     domain.FizzBuzzInput
+		expected  domain.FizzBuzzCounts
+		expectErr bool
+	}{
+		{
+			name:  "Basic FizzBuzz",
+			input: domain.FizzBuzzInput{Int1: 3, Int2: 5, Limit: 100, Str1: "fizz", Str2: "buzz"},
+			expected: domain.FizzBuzzCounts{First: 1, Last: 100, Plain: 53, Combinations: []domain.CombinationCount{
+				{Rules: []int{0}, Words: []string{"fizz"}, Value: "fizz", Count: 27},
+				{Rules: []int{1}, Words: []string{"buzz"}, Value: "buzz", Count: 14},
+				{Rules: []int{0, 1}, Words: []string{"fizz", "buzz"}, Value: "fizzbuzz", Count: 6},
+			}},
+		},
+		{
+			name:  "Divisors sharing factors",
+			input: domain.FizzBuzzInput{Int1: 4, Int2: -6, Limit: 24, Str1: "fizz", Str2: "buzz"},
+			expected: domain.FizzBuzzCounts{First: 1, Last: 24, Plain: 16, Combinations: []domain.CombinationCount{
+				{Rules: []int{0}, Words: []string{"fizz"}, Value: "fizz", Count: 4},
+				{Rules: []int{1}, Words: []string{"buzz"}, Value: "buzz", Count: 2},
+				{Rules: []int{0, 1}, Words: []string{"fizz", "buzz"}, Value: "fizzbuzz", Count: 2},
+			}},
+		},
+		{
+			name:  "Window",
+			input: domain.FizzBuzzInput{Int1: 3, Int2: 5, Limit: 100, Str1: "fizz", Str2: "buzz", Start: 11, Count: 5},
+			expected: domain.FizzBuzzCounts{First: 11, Last: 15, Plain: 3, Combinations: []domain.CombinationCount{
+				{Rules: []int{0}, Words: []string{"fizz"}, Value: "fizz", Count: 1},
+				{Rules: []int{0, 1}, Words: []string{"fizz", "buzz"}, Value: "fizzbuzz", Count: 1},
+			}},
+		},
+		{
+			name:  "Limit near MaxInt64",
+			input: domain.FizzBuzzInput{Int1: 3, Int2: 5, Limit: math.MaxInt64, Str1: "fizz", Str2: "buzz"},
+			expected: domain.FizzBuzzCounts{First: 1, Last: math.MaxInt64, Plain: 4919131752989213764, Combinations: []domain.CombinationCount{
+				{Rules: []int{0}, Words: []string{"fizz"}, Value: "fizz", Count: 2459565876494606882},
+				{Rules: []int{1}, Words: []string{"buzz"}, Value: "buzz", Count: 1229782938247303441},
+				{Rules: []int{0, 1}, Words: []string{"fizz", "buzz"}, Value: "fizzbuzz", Count: 614891469123651720},
+			}},
+		},
+		{
+			name: "Three rules",
+			input: domain.FizzBuzzInput{Limit: 105, Rules: domain.Rules{
+				{Divisor: 3, Word: "fizz"}, {Divisor: 5, Word: "buzz"}, {Divisor: 7, Word: "bazz"},
+			}},
+			expected: domain.FizzBuzzCounts{First: 1, Last: 105, Plain: 48, Combinations: []domain.CombinationCount{
+				{Rules: []int{0}, Words: []string{"fizz"}, Value: "fizz", Count: 24},
+				{Rules: []int{1}, Words: []string{"buzz"}, Value: "buzz", Count: 12},
+				{Rules: []int{0, 1}, Words: []string{"fizz", "buzz"}, Value: "fizzbuzz", Count: 6},
+				{Rules: []int{2}, Words: []string{"bazz"}, Value: "bazz", Count: 8},
+				{Rules: []int{0, 2}, Words: []string{"fizz", "bazz"}, Value: "fizzbazz", Count: 4},
+				{Rules: []int{1, 2}, Words: []string{"buzz", "bazz"}, Value: "buzzbazz", Count: 2},
+				{Rules: []int{0, 1, 2}, Words: []string{"fizz", "buzz", "bazz"}, Value: "fizzbuzzbazz", Count: 1},
+			}},
+		},
+		{
+			name:      "Invalid limit",
+			input:     domain.FizzBuzzInput{Int1: 3, Int2: 5, Limit: 0, Str1: "fizz", Str2: "buzz"},
+			expectErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := svc.CountFizzBuzz(context.Background(), tt.input)
+
+			if tt.expectErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, tt.expected, result)
+			}
+		})
+	}
+}
+
+// TestGenerateFizzBuzzBatch /
+func TestGenerateFizzBuzzBatch(t *testing.T) {
+	repo := repository.NewMemoryFizzBuzzRepository()
+	svc := service.NewFizzBuzzService(repo)
+
+	results, err := svc.GenerateFizzBuzzBatch(context.Background(), []domain.FizzBuzzInput{
+		{Int1: 3, Int2: 5, Limit: 5, Str1: "fizz", Str2: "buzz"},
+		{Int1: 3, Int2: 5, Limit: 5, Str1: "", Str2: "buzz"},
+		{Int1: 2, Int2: 3, Limit: 6, Str1: "foo", Str2: "bar"},
+	})
+	require.NoError(t, err)
+	require.Len(t, results, 3)
+
+	assert.Equal(t, "1,2,fizz,4,buzz", results[0].Result)
+	assert.Nil(t, results[0].Err)
+
+	require.Error(t, results[1].Err)
+	assert.Equal(t, "invalid_input", results[1].Err.Kind())
+
+	assert.Equal(t, "1,foo,bar,foo,5,foobar", results[2].Result)
+	assert.Nil(t, results[2].Err)
+}
+
+// TestFizzBuzzServiceCancellation /
+func TestFizzBuzzServiceCancellation(t *testing.T) {
+	svc := service.NewFizzBuzzService(repository.NewMemoryFizzBuzzRepository())
+	// Long enough for the generation loop to check the context
+	input := domain.FizzBuzzInput{Int1: 3, Int2: 5, Limit: 10000, Str1: "fizz", Str2: "buzz"}
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	expired, cancelExpired := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancelExpired()
+
+	calls := map[string]func(ctx context.Context) error{
+		"Generate": func(ctx context.Context) error {
+			_, err := svc.GenerateFizzBuzz(ctx, input)
+			return err
+		},
+		"Batch": func(ctx context.Context) error {
+			_, err := svc.GenerateFizzBuzzBatch(ctx, []domain.FizzBuzzInput{input, input})
+			return err
+		},
+		"Stream": func(ctx context.Context) error {
+			return svc.StreamFizzBuzz(ctx, input, func(domain.Term) error { return nil })
+		},
+	}
+
+	tests := []struct {
+		name   string
+		ctx    context.Context
+		kind   string
+		status int
+	}{
+		{name: "Cancelled", ctx: cancelled, kind: "request_cancelled", status: service.StatusClientClosedRequest},
+		{name: "Deadline Exceeded", ctx: expired, kind: "request_timeout", status: http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		for name, call := range calls {
+			t.Run(tt.name+" "+name, func(t *testing.T) {
+				err := call(tt.ctx)
+				require.Error(t, err)
+
+				var gErr gapierrors.Error
+				require.True(t, errors.As(err, &gErr))
+				assert.Equal(t, tt.kind, gErr.Kind())
+				assert.Equal(t, tt.status, gErr.StatusCode())
+			})
+		}
+	}
+}
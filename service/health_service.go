@@ -0,0 +1,96 @@
+package service
+
+import (
+	"context"
+	"lbc/fizzbuzz/domain"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mwm-io/gapi/errors"
+)
+
+// shutdownCheck is the check failing once the server is shutting down
+const shutdownCheck = "shutdown"
+
+// HealthCheck checks a single dependency, returning an error when it is not usable
+type HealthCheck func(ctx context.Context) errors.Error
+
+type HealthService interface {
+	Ready(ctx context.Context) domain.Health
+	// Shutdown makes the server not ready, so that it stops receiving traffic while it drains
+	Shutdown()
+}
+
+type healthService struct {
+	checks       map[string]HealthCheck
+	timeout      time.Duration
+	shuttingDown atomic.Bool
+}
+
+// NewHealthService returns a HealthService running checks, each one within timeout
+func NewHealthService(checks map[string]HealthCheck, timeout time.Duration) HealthService {
+	return &healthService{
+		checks:  checks,
+		timeout: timeout,
+	}
+}
+
+// Ready runs every check concurrently, the server being ready when all of them succeed and it is not shutting down
+func (s *healthService) Ready(ctx context.Context) domain.Health {
+	health := domain.Health{
+		Status: domain.HealthStatusOK,
+		Checks: make(map[string]domain.HealthCheck, len(s.checks)+1),
+	}
+
+	shutdown := domain.HealthCheck{Status: domain.HealthStatusOK}
+	if s.shuttingDown.Load() {
+		shutdown = domain.HealthCheck{Status: domain.HealthStatusFailing, Error: "the server is shutting down"}
+	}
+	health.Checks[shutdownCheck] = shutdown
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, check := range s.checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result := s.run(ctx, check)
+			mu.Lock()
+			health.Checks[name] = result
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	for _, check := range health.Checks {
+		if check.Status != domain.HealthStatusOK {
+			health.Status = domain.HealthStatusFailing
+		}
+	}
+
+	return health
+}
+
+func (s *healthService) Shutdown() {
+	s.shuttingDown.Store(true)
+}
+
+// run runs check within the timeout and measures its latency
+func (s *healthService) run(ctx context.Context, check HealthCheck) domain.HealthCheck {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := check(ctx)
+	result := domain.HealthCheck{
+		Status:    domain.HealthStatusOK,
+		LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if err != nil {
+		result.Status = domain.HealthStatusFailing
+		result.Error = err.Error()
+	}
+
+	return result
+}
@@ -0,0 +1,90 @@
+package service_test
+
+import (
+	"context"
+	"lbc/fizzbuzz/domain"
+	"lbc/fizzbuzz/service"
+	"testing"
+	"time"
+
+	"github.com/mwm-io/gapi/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHealthServiceReady(t *testing.T) {
+	ok := func(ctx context.Context) errors.Error { return nil }
+	failing := func(ctx context.Context) errors.Error {
+		return errors.ServiceUnavailable("database_unavailable", "connection refused")
+	}
+	slow := func(ctx context.Context) errors.Error {
+		<-ctx.Done()
+		return errors.Wrap(ctx.Err())
+	}
+
+	tests := []struct {
+		name           string
+		checks         map[string]service.HealthCheck
+		shutdown       bool
+		expectedStatus string
+		expectedChecks map[string]string
+		expectedErrors map[string]string
+	}{
+		{
+			name:           "No Dependency",
+			expectedStatus: domain.HealthStatusOK,
+			expectedChecks: map[string]string{"shutdown": domain.HealthStatusOK},
+		},
+		{
+			name:           "Every Check Succeeds",
+			checks:         map[string]service.HealthCheck{"postgres": ok, "migrations": ok},
+			expectedStatus: domain.HealthStatusOK,
+			expectedChecks: map[string]string{"shutdown": domain.HealthStatusOK, "postgres": domain.HealthStatusOK, "migrations": domain.HealthStatusOK},
+		},
+		{
+			name:           "A Check Fails",
+			checks:         map[string]service.HealthCheck{"postgres": failing, "migrations": ok},
+			expectedStatus: domain.HealthStatusFailing,
+			expectedChecks: map[string]string{"shutdown": domain.HealthStatusOK, "postgres": domain.HealthStatusFailing, "migrations": domain.HealthStatusOK},
+			expectedErrors: map[string]string{"postgres": "connection refused"},
+		},
+		{
+			name:           "A Check Times Out",
+			checks:         map[string]service.HealthCheck{"postgres": slow},
+			expectedStatus: domain.HealthStatusFailing,
+			expectedChecks: map[string]string{"shutdown": domain.HealthStatusOK, "postgres": domain.HealthStatusFailing},
+			expectedErrors: map[string]string{"postgres": "context deadline exceeded"},
+		},
+		{
+			name:           "Shutting Down",
+			checks:         map[string]service.HealthCheck{"postgres": ok},
+			shutdown:       true,
+			expectedStatus: domain.HealthStatusFailing,
+			expectedChecks: map[string]string{"shutdown": domain.HealthStatusFailing, "postgres": domain.HealthStatusOK},
+			expectedErrors: map[string]string{"shutdown": "the server is shutting down"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			healthService := service.NewHealthService(tt.checks, 10*time.Millisecond)
+			if tt.shutdown {
+				healthService.Shutdown()
+			}
+
+			health := healthService.Ready(context.Background())
+			assert.Equal(t, tt.expectedStatus, health.Status)
+			assert.Equal(t, tt.expectedStatus == domain.HealthStatusOK, health.OK())
+
+			statuses := make(map[string]string, len(health.Checks))
+			for name, check := range health.Checks {
+				statuses[name] = check.Status
+				assert.Equal(t, tt.expectedErrors[name], check.Error, name)
+			}
+			assert.Equal(t, tt.expectedChecks, statuses)
+		})
+	}
+
+	// The latency of a check is its duration, bounded by the timeout
+	health := service.NewHealthService(map[string]service.HealthCheck{"postgres": slow}, 20*time.Millisecond).Ready(context.Background())
+	assert.InDelta(t, 20, health.Checks["postgres"].LatencyMs, 15)
+}
@@ -0,0 +1,491 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	stderrors "errors"
+	"lbc/fizzbuzz/domain"
+	"lbc/fizzbuzz/internal"
+	"lbc/fizzbuzz/repository"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/mwm-io/gapi/errors"
+	"go.uber.org/zap"
+)
+
+const (
+	// progressCheckInterval is the number of terms written between two checks for a progress update
+	progressCheckInterval = 64 * 1024
+	// progressUpdateInterval is the minimum delay between two persisted progress updates of a job
+	progressUpdateInterval = time.Second
+	// resultBufferSize is the size of the buffer terms are written to before reaching the result file
+	resultBufferSize = 64 * 1024
+	// cleanupInterval is the delay between two deletions of the expired jobs
+	cleanupInterval = 10 * time.Minute
+)
+
+type JobService interface {
+	Start(ctx context.Context) errors.Error
+	Close(ctx context.Context)
+	Submit(ctx context.Context, input domain.FizzBuzzInput) (domain.FizzbuzzJob, errors.Error)
+	Get(ctx context.Context, id string) (domain.FizzbuzzJob, errors.Error)
+	Cancel(ctx context.Context, id string) (domain.FizzbuzzJob, errors.Error)
+	ResultPath(ctx context.Context, id string) (string, errors.Error)
+}
+
+type jobService struct {
+	jobRepository      repository.JobRepository
+	fizzBuzzRepository repository.FizzBuzzRepository
+	config             internal.JobsConfig
+	logger             *zap.Logger
+
+	queue   chan string
+	stop    chan struct{}
+	workers sync.WaitGroup
+
+	mu      sync.Mutex
+	closed  bool
+	running map[string]*runningJob
+	// cancelling holds the queued jobs being cancelled, which workers skip
+	cancelling map[string]struct{}
+}
+
+// runningJob is a job currently handled by a worker
+type runningJob struct {
+	cancel context.CancelFunc
+	// cancelled is set when the job is cancelled on request, rather than interrupted by Close
+	cancelled bool
+	// done is closed once the worker recorded the outcome of the job
+	done chan struct{}
+}
+
+// NewJobService returns a JobService recording the hits of the submitted jobs in fizzBuzzRepository
+func NewJobService(
+	jobRepository repository.JobRepository,
+	fizzBuzzRepository repository.FizzBuzzRepository,
+	config internal.JobsConfig,
+	logger *zap.Logger) JobService {
+	return &jobService{
+		jobRepository:      jobRepository,
+		fizzBuzzRepository: fizzBuzzRepository,
+		config:             config,
+		logger:             logger,
+		queue:              make(chan string, config.QueueSize),
+		stop:               make(chan struct{}),
+		running:            make(map[string]*runningJob),
+		cancelling:         make(map[string]struct{}),
+	}
+}
+
+// Start launches the workers and the deletion of expired jobs, then queues again the jobs left unfinished by a previous run
+func (s *jobService) Start(ctx context.Context) errors.Error {
+	if err := os.MkdirAll(s.config.ResultDir, 0o755); err != nil {
+		return errors.Wrap(err).WithKind("internal_error")
+	}
+
+	for i := 0; i < s.config.Workers; i++ {
+		s.workers.Add(1)
+		go s.work()
+	}
+	s.workers.Add(1)
+	go s.cleanup()
+
+	jobs, err := s.jobRepository.ListUnfinished(ctx)
+	if err != nil {
+		return err
+	}
+
+	if len(jobs) > 0 {
+		s.logger.Info("Resuming unfinished jobs", zap.Int("count", len(jobs)))
+	}
+
+	go func() {
+		for _, job := range jobs {
+			select {
+			case s.queue <- job.ID:
+			case <-s.stop:
+				return
+			}
+		}
+	}()
+
+	return nil
+}
+
+// Close stops accepting jobs and waits for the running ones to complete.
+// When ctx is done first, running jobs are interrupted and queued again for the next start.
+func (s *jobService) Close(ctx context.Context) {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return
+	}
+	s.closed = true
+	s.mu.Unlock()
+
+	close(s.stop)
+
+	done := make(chan struct{})
+	go func() {
+		s.workers.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		s.mu.Lock()
+		for _, job := range s.running {
+			job.cancel()
+		}
+		s.mu.Unlock()
+		<-done
+	}
+}
+
+// Submit persists a new queued job and hands it to the workers
+func (s *jobService) Submit(ctx context.Context, input domain.FizzBuzzInput) (domain.FizzbuzzJob, errors.Error) {
+	if err := input.Validate(); err != nil {
+		return domain.FizzbuzzJob{}, errors.Wrap(err).WithKind("invalid_input")
+	}
+
+	s.mu.Lock()
+	closed := s.closed
+	s.mu.Unlock()
+	if closed {
+		return domain.FizzbuzzJob{}, errors.ServiceUnavailable("jobs_closed", "jobs are not accepted anymore, retry later")
+	}
+
+	id, err := newJobID()
+	if err != nil {
+		return domain.FizzbuzzJob{}, err
+	}
+
+	first, last := input.Window()
+	job := domain.FizzbuzzJob{
+		ID:        id,
+		Input:     input,
+		Status:    domain.JobStatusQueued,
+		Total:     last - first + 1,
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := s.jobRepository.Create(ctx, job); err != nil {
+		return domain.FizzbuzzJob{}, err
+	}
+
+	select {
+	case s.queue <- job.ID:
+		// The hit is recorded once the job is accepted, a job resumed after a restart being generated again without being recorded.
+		// The job being queued already, failing to record it does not fail the request.
+		if err := s.fizzBuzzRepository.Save(ctx, input); err != nil {
+			s.logger.Error("Failed to record job hit", zap.Error(err), zap.String("id", job.ID))
+		}
+		return job, nil
+	default:
+		s.finish(&job, domain.JobStatusFailed, "job queue is full")
+		return domain.FizzbuzzJob{}, errors.ServiceUnavailable("job_queue_full", "too many jobs are waiting, retry later")
+	}
+}
+
+// Get returns a job, or an error when the job succeeded but its result file is gone
+func (s *jobService) Get(ctx context.Context, id string) (domain.FizzbuzzJob, errors.Error) {
+	job, err := s.jobRepository.Get(ctx, id)
+	if err != nil {
+		return domain.FizzbuzzJob{}, err
+	}
+
+	if job.Status == domain.JobStatusSucceeded {
+		if err := s.checkResult(id); err != nil {
+			return domain.FizzbuzzJob{}, err
+		}
+	}
+
+	return job, nil
+}
+
+// Cancel stops a running job, or prevents a queued one from running.
+// A running job is returned once its worker recorded its outcome, which is not cancelled when it completed meanwhile.
+func (s *jobService) Cancel(ctx context.Context, id string) (domain.FizzbuzzJob, errors.Error) {
+	job, err := s.jobRepository.Get(ctx, id)
+	if err != nil {
+		return job, err
+	}
+
+	if job.Finished() {
+		return job, errors.Conflict("job_finished", "job %s is already %s", id, job.Status)
+	}
+
+	// The lock only guards the state of the workers, so that repository calls never hold them up
+	s.mu.Lock()
+	running, ok := s.running[id]
+	if ok {
+		// The worker records the outcome once generation has stopped
+		running.cancelled = true
+		running.cancel()
+		s.mu.Unlock()
+
+		select {
+		case <-running.done:
+		case <-ctx.Done():
+			return job, contextError(ctx)
+		}
+
+		return s.jobRepository.Get(ctx, id)
+	}
+	s.cancelling[id] = struct{}{}
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.cancelling, id)
+		s.mu.Unlock()
+	}()
+
+	// Workers now skip the job, but one may have finished it since it was read
+	job, err = s.jobRepository.Get(ctx, id)
+	if err != nil {
+		return job, err
+	}
+
+	if job.Finished() {
+		return job, errors.Conflict("job_finished", "job %s is already %s", id, job.Status)
+	}
+
+	now := time.Now().UTC()
+	job.Status = domain.JobStatusCancelled
+	job.FinishedAt = &now
+	if err := s.jobRepository.Update(ctx, job); err != nil {
+		return job, err
+	}
+
+	return job, nil
+}
+
+// ResultPath returns the path of the result file of a succeeded job
+func (s *jobService) ResultPath(ctx context.Context, id string) (string, errors.Error) {
+	job, err := s.jobRepository.Get(ctx, id)
+	if err != nil {
+		return "", err
+	}
+
+	if job.Status != domain.JobStatusSucceeded {
+		return "", errors.Conflict("job_not_succeeded", "job %s is %s", id, job.Status)
+	}
+
+	if err := s.checkResult(id); err != nil {
+		return "", err
+	}
+
+	return s.resultPath(id), nil
+}
+
+// checkResult returns an error when the result file of a succeeded job is missing,
+// e.g. when the result directory was wiped, rather than letting clients download nothing
+func (s *jobService) checkResult(id string) errors.Error {
+	_, err := os.Stat(s.resultPath(id))
+	switch {
+	case err == nil:
+		return nil
+	case stderrors.Is(err, os.ErrNotExist):
+		return errors.Wrap(err).WithKind("job_result_missing").WithStatus(http.StatusGone).
+			WithMessage("the result of job %s is no longer available", id)
+	default:
+		return errors.Wrap(err).WithKind("job_result_unreadable")
+	}
+}
+
+// work runs queued jobs until the service is closed
+func (s *jobService) work() {
+	defer s.workers.Done()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case id := <-s.queue:
+			s.run(id)
+		}
+	}
+}
+
+// run generates the result of a job and records its outcome
+func (s *jobService) run(id string) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	s.mu.Lock()
+	if s.closed {
+		// The job stays queued and is resumed on next start
+		s.mu.Unlock()
+		return
+	}
+	if _, ok := s.cancelling[id]; ok {
+		// The job is recorded as cancelled by Cancel, or resumed on next start if that failed
+		s.mu.Unlock()
+		return
+	}
+	running := &runningJob{cancel: cancel, done: make(chan struct{})}
+	s.running[id] = running
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.running, id)
+		s.mu.Unlock()
+		close(running.done)
+	}()
+
+	// Repository calls do not use ctx, so that the outcome is recorded even when generation is interrupted
+	job, err := s.jobRepository.Get(context.Background(), id)
+	if err != nil {
+		s.logger.Error("Failed to load job", zap.Error(err), zap.String("id", id))
+		return
+	}
+
+	if job.Finished() {
+		return
+	}
+
+	now := time.Now().UTC()
+	job.Status = domain.JobStatusRunning
+	job.StartedAt = &now
+	job.Generated = 0
+	if err := s.jobRepository.Update(context.Background(), job); err != nil {
+		return
+	}
+
+	err = s.generate(ctx, &job)
+
+	s.mu.Lock()
+	cancelled := running.cancelled
+	s.mu.Unlock()
+
+	switch {
+	case err == nil:
+		s.finish(&job, domain.JobStatusSucceeded, "")
+	case cancelled:
+		s.finish(&job, domain.JobStatusCancelled, "")
+	case ctx.Err() != nil:
+		// Interrupted by Close, the job starts over on next start
+		job.Status = domain.JobStatusQueued
+		job.StartedAt = nil
+		job.Generated = 0
+		_ = s.jobRepository.Update(context.Background(), job)
+	default:
+		s.logger.Error("Job failed", zap.Error(err), zap.String("id", id))
+		s.finish(&job, domain.JobStatusFailed, err.Message())
+	}
+}
+
+// generate writes the terms of the job to its result file, persisting the progress along the way
+func (s *jobService) generate(ctx context.Context, job *domain.FizzbuzzJob) errors.Error {
+	tmpPath := s.resultPath(job.ID) + ".tmp"
+	file, err := os.Create(tmpPath)
+	if err != nil {
+		return errors.Wrap(err).WithKind("internal_error")
+	}
+	defer func() {
+		_ = file.Close()
+		_ = os.Remove(tmpPath)
+	}()
+
+	w := bufio.NewWriterSize(file, resultBufferSize)
+	lastUpdate := time.Now()
+	_, genErr := streamTerms(ctx, job.Input, func(term domain.Term) error {
+		if _, err := w.WriteString(term.Value); err != nil {
+			return err
+		}
+		if err := w.WriteByte('\n'); err != nil {
+			return err
+		}
+
+		job.Generated++
+		if job.Generated%progressCheckInterval == 0 && time.Since(lastUpdate) >= progressUpdateInterval {
+			lastUpdate = time.Now()
+			_ = s.jobRepository.Update(context.Background(), *job)
+		}
+
+		return nil
+	})
+	if genErr != nil {
+		return genErr
+	}
+
+	if err := w.Flush(); err != nil {
+		return errors.Wrap(err).WithKind("internal_error")
+	}
+
+	if err := file.Close(); err != nil {
+		return errors.Wrap(err).WithKind("internal_error")
+	}
+
+	if err := os.Rename(tmpPath, s.resultPath(job.ID)); err != nil {
+		return errors.Wrap(err).WithKind("internal_error")
+	}
+
+	return nil
+}
+
+// cleanup deletes the expired jobs and their result files until the service is closed
+func (s *jobService) cleanup() {
+	defer s.workers.Done()
+
+	ticker := time.NewTicker(cleanupInterval)
+	defer ticker.Stop()
+	for {
+		s.deleteExpired()
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// deleteExpired deletes the jobs finished for longer than the retention, and their result files
+func (s *jobService) deleteExpired() {
+	ids, err := s.jobRepository.DeleteFinishedBefore(context.Background(), time.Now().UTC().Add(-s.config.Retention))
+	if err != nil {
+		s.logger.Error("Failed to delete expired jobs", zap.Error(err))
+		return
+	}
+
+	for _, id := range ids {
+		// Only succeeded jobs have a result file
+		if err := os.Remove(s.resultPath(id)); err != nil && !os.IsNotExist(err) {
+			s.logger.Error("Failed to delete job result", zap.Error(err), zap.String("id", id))
+		}
+	}
+	if len(ids) > 0 {
+		s.logger.Info("Deleted expired jobs", zap.Int("count", len(ids)))
+	}
+}
+
+// finish records the final status of a job
+func (s *jobService) finish(job *domain.FizzbuzzJob, status, message string) {
+	now := time.Now().UTC()
+	job.Status = status
+	job.Error = message
+	job.FinishedAt = &now
+	_ = s.jobRepository.Update(context.Background(), *job)
+}
+
+func (s *jobService) resultPath(id string) string {
+	return filepath.Join(s.config.ResultDir, id+".txt")
+}
+
+// newJobID returns a random 128 bits hexadecimal identifier
+func newJobID() (string, errors.Error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", errors.Wrap(err).WithKind("internal_error")
+	}
+
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,316 @@
+package service_test
+
+import (
+	"context"
+	"lbc/fizzbuzz/domain"
+	"lbc/fizzbuzz/internal"
+	"lbc/fizzbuzz/repository"
+	"lbc/fizzbuzz/service"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mwm-io/gapi/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// newJobService returns a started JobService writing its results to a temporary directory
+func newJobService(t *testing.T, workers int) (service.JobService, repository.JobRepository) {
+	jobRepository := repository.NewMemoryJobRepository()
+	jobService := service.NewJobService(jobRepository, repository.NewMemoryFizzBuzzRepository(), internal.JobsConfig{
+		Workers:   workers,
+		QueueSize: 10,
+		ResultDir: t.TempDir(),
+		Retention: time.Hour,
+	}, zap.NewExample())
+	require.Nil(t, jobService.Start(context.Background()))
+	t.Cleanup(func() { jobService.Close(context.Background()) })
+
+	return jobService, jobRepository
+}
+
+// waitJob polls the job until it reaches a final status
+func waitJob(t *testing.T, jobService service.JobService, id string) domain.FizzbuzzJob {
+	var job domain.FizzbuzzJob
+	require.Eventually(t, func() bool {
+		var err error
+		job, err = jobService.Get(context.Background(), id)
+		require.Nil(t, err)
+		return job.Finished()
+	}, 10*time.Second, 10*time.Millisecond)
+
+	return job
+}
+
+func TestJobServiceSubmit(t *testing.T) {
+	jobService, _ := newJobService(t, 2)
+	ctx := context.Background()
+
+	tests := []struct {
+		name     string
+		input    domain.FizzBuzzInput
+		expected string
+		errKind  string
+	}{
+		{
+			name:     "Basic FizzBuzz",
+			input:    domain.FizzBuzzInput{Int1: 3, Int2: 5, Limit: 15, Str1: "fizz", Str2: "buzz"},
+			expected: "1\n2\nfizz\n4\nbuzz\nfizz\n7\n8\nfizz\nbuzz\n11\nfizz\n13\n14\nfizzbuzz\n",
+		},
+		{
+			name:     "Window",
+			input:    domain.FizzBuzzInput{Int1: 3, Int2: 5, Limit: 100, Str1: "fizz", Str2: "buzz", Start: 13, Count: 3},
+			expected: "13\n14\nfizzbuzz\n",
+		},
+		{
+			name:    "Invalid input",
+			input:   domain.FizzBuzzInput{Int1: 0, Int2: 5, Limit: 15, Str1: "fizz", Str2: "buzz"},
+			errKind: "invalid_input",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			job, err := jobService.Submit(ctx, tt.input)
+			if tt.errKind != "" {
+				require.NotNil(t, err)
+				assert.Equal(t, tt.errKind, err.Kind())
+				return
+			}
+			require.Nil(t, err)
+			assert.Equal(t, domain.JobStatusQueued, job.Status)
+
+			job = waitJob(t, jobService, job.ID)
+			assert.Equal(t, domain.JobStatusSucceeded, job.Status)
+			assert.Equal(t, job.Total, job.Generated)
+			assert.Equal(t, 1.0, job.Progress())
+
+			path, err := jobService.ResultPath(ctx, job.ID)
+			require.Nil(t, err)
+			result, readErr := os.ReadFile(path)
+			require.NoError(t, readErr)
+			assert.Equal(t, tt.expected, string(result))
+
+			_, err = jobService.Cancel(ctx, job.ID)
+			require.NotNil(t, err)
+			assert.Equal(t, "job_finished", err.Kind())
+		})
+	}
+}
+
+func TestJobServiceCancel(t *testing.T) {
+	jobService, _ := newJobService(t, 1)
+	ctx := context.Background()
+
+	// The first job keeps the only worker busy, so that the second one stays queued
+	running, err := jobService.Submit(ctx, domain.FizzBuzzInput{Int1: 3, Int2: 5, Limit: domain.MaxLimit, Str1: "fizz", Str2: "buzz"})
+	require.Nil(t, err)
+	queued, err := jobService.Submit(ctx, domain.FizzBuzzInput{Int1: 3, Int2: 5, Limit: 15, Str1: "fizz", Str2: "buzz"})
+	require.Nil(t, err)
+
+	_, err = jobService.Cancel(ctx, queued.ID)
+	require.Nil(t, err)
+	job := waitJob(t, jobService, queued.ID)
+	assert.Equal(t, domain.JobStatusCancelled, job.Status)
+
+	_, err = jobService.Cancel(ctx, running.ID)
+	require.Nil(t, err)
+	job = waitJob(t, jobService, running.ID)
+	assert.Equal(t, domain.JobStatusCancelled, job.Status)
+
+	_, err = jobService.ResultPath(ctx, running.ID)
+	require.NotNil(t, err)
+	assert.Equal(t, "job_not_succeeded", err.Kind())
+
+	_, err = jobService.Get(ctx, "unknown")
+	require.NotNil(t, err)
+	assert.Equal(t, "job_not_found", err.Kind())
+}
+
+func TestJobServiceResultMissing(t *testing.T) {
+	ctx := context.Background()
+	resultDir := t.TempDir()
+	jobService := service.NewJobService(repository.NewMemoryJobRepository(), repository.NewMemoryFizzBuzzRepository(),
+		internal.JobsConfig{Workers: 1, QueueSize: 10, ResultDir: resultDir, Retention: time.Hour}, zap.NewExample())
+	require.Nil(t, jobService.Start(ctx))
+	defer jobService.Close(ctx)
+
+	job, err := jobService.Submit(ctx, domain.FizzBuzzInput{Int1: 3, Int2: 5, Limit: 15, Str1: "fizz", Str2: "buzz"})
+	require.Nil(t, err)
+	job = waitJob(t, jobService, job.ID)
+	require.Equal(t, domain.JobStatusSucceeded, job.Status)
+
+	// The result directory was wiped, e.g. by a restart
+	require.NoError(t, os.RemoveAll(resultDir))
+
+	_, err = jobService.Get(ctx, job.ID)
+	require.NotNil(t, err)
+	assert.Equal(t, "job_result_missing", err.Kind())
+	assert.Equal(t, http.StatusGone, err.StatusCode())
+
+	_, err = jobService.ResultPath(ctx, job.ID)
+	require.NotNil(t, err)
+	assert.Equal(t, "job_result_missing", err.Kind())
+}
+
+func TestJobServiceResume(t *testing.T) {
+	_, jobRepository := newJobService(t, 0)
+	ctx := context.Background()
+
+	// A job left running by a previous process
+	startedAt := time.Now().UTC()
+	require.Nil(t, jobRepository.Create(ctx, domain.FizzbuzzJob{
+		ID:        "interrupted",
+		Input:     domain.FizzBuzzInput{Int1: 3, Int2: 5, Limit: 15, Str1: "fizz", Str2: "buzz"},
+		Status:    domain.JobStatusRunning,
+		Generated: 7,
+		Total:     15,
+		CreatedAt: startedAt,
+		StartedAt: &startedAt,
+	}))
+
+	jobService := service.NewJobService(jobRepository, repository.NewMemoryFizzBuzzRepository(),
+		internal.JobsConfig{Workers: 1, QueueSize: 10, ResultDir: t.TempDir(), Retention: time.Hour}, zap.NewExample())
+	require.Nil(t, jobService.Start(ctx))
+	defer jobService.Close(ctx)
+
+	job := waitJob(t, jobService, "interrupted")
+	assert.Equal(t, domain.JobStatusSucceeded, job.Status)
+	assert.Equal(t, 15, job.Generated)
+}
+
+func TestJobServiceHitsAndRetention(t *testing.T) {
+	ctx := context.Background()
+	jobRepository := repository.NewMemoryJobRepository()
+	fizzBuzzRepository := repository.NewMemoryFizzBuzzRepository()
+	resultDir := t.TempDir()
+
+	// A job finished before the retention, whose result is still on disk
+	finishedAt := time.Now().UTC().Add(-2 * time.Hour)
+	require.Nil(t, jobRepository.Create(ctx, domain.FizzbuzzJob{
+		ID:         "expired",
+		Input:      domain.FizzBuzzInput{Int1: 3, Int2: 5, Limit: 15, Str1: "fizz", Str2: "buzz"},
+		Status:     domain.JobStatusSucceeded,
+		CreatedAt:  finishedAt,
+		FinishedAt: &finishedAt,
+	}))
+	expiredPath := filepath.Join(resultDir, "expired.txt")
+	require.NoError(t, os.WriteFile(expiredPath, []byte("1\n"), 0o644))
+
+	// A job left running by a previous process, whose hit was recorded when it was submitted
+	input := domain.FizzBuzzInput{Int1: 2, Int2: 7, Limit: 20, Str1: "foo", Str2: "bar"}
+	require.Nil(t, jobRepository.Create(ctx, domain.FizzbuzzJob{
+		ID:        "interrupted",
+		Input:     input,
+		Status:    domain.JobStatusRunning,
+		Total:     20,
+		CreatedAt: time.Now().UTC(),
+	}))
+
+	jobService := service.NewJobService(jobRepository, fizzBuzzRepository,
+		internal.JobsConfig{Workers: 1, QueueSize: 10, ResultDir: resultDir, Retention: time.Hour}, zap.NewExample())
+	require.Nil(t, jobService.Start(ctx))
+	defer jobService.Close(ctx)
+
+	job := waitJob(t, jobService, "interrupted")
+	assert.Equal(t, domain.JobStatusSucceeded, job.Status)
+	_, err := fizzBuzzRepository.GetMostHits(ctx)
+	require.NotNil(t, err, "resuming a job records no hit")
+	assert.Equal(t, "no_requests", err.Kind())
+
+	submitted, err := jobService.Submit(ctx, input)
+	require.Nil(t, err)
+	waitJob(t, jobService, submitted.ID)
+	mostHits, err := fizzBuzzRepository.GetMostHits(ctx)
+	require.Nil(t, err)
+	assert.Equal(t, 1, mostHits.Hits)
+
+	_, err = jobService.Get(ctx, "expired")
+	require.NotNil(t, err)
+	assert.Equal(t, "job_not_found", err.Kind())
+	assert.NoFileExists(t, expiredPath)
+}
+
+func TestJobServiceQueueFull(t *testing.T) {
+	ctx := context.Background()
+	fizzBuzzRepository := repository.NewMemoryFizzBuzzRepository()
+	// Without workers, the queue is never drained
+	jobService := service.NewJobService(repository.NewMemoryJobRepository(), fizzBuzzRepository,
+		internal.JobsConfig{Workers: 0, QueueSize: 1, ResultDir: t.TempDir(), Retention: time.Hour}, zap.NewExample())
+	require.Nil(t, jobService.Start(ctx))
+	defer jobService.Close(ctx)
+
+	input := domain.FizzBuzzInput{Int1: 3, Int2: 5, Limit: 15, Str1: "fizz", Str2: "buzz"}
+	_, err := jobService.Submit(ctx, input)
+	require.Nil(t, err)
+
+	_, err = jobService.Submit(ctx, input)
+	require.NotNil(t, err)
+	assert.Equal(t, "job_queue_full", err.Kind())
+
+	// Only the queued job is recorded
+	mostHits, err := fizzBuzzRepository.GetMostHits(ctx)
+	require.Nil(t, err)
+	assert.Equal(t, 1, mostHits.Hits)
+}
+
+// blockingJobRepository blocks the update cancelling a job until release is closed
+type blockingJobRepository struct {
+	repository.JobRepository
+	entered chan struct{}
+	release chan struct{}
+}
+
+func (r *blockingJobRepository) Update(ctx context.Context, job domain.FizzbuzzJob) errors.Error {
+	if job.Status == domain.JobStatusCancelled {
+		close(r.entered)
+		<-r.release
+	}
+
+	return r.JobRepository.Update(ctx, job)
+}
+
+func TestJobServiceCancelDoesNotBlock(t *testing.T) {
+	ctx := context.Background()
+	jobRepository := &blockingJobRepository{
+		JobRepository: repository.NewMemoryJobRepository(),
+		entered:       make(chan struct{}),
+		release:       make(chan struct{}),
+	}
+	// Without workers, jobs stay queued
+	jobService := service.NewJobService(jobRepository, repository.NewMemoryFizzBuzzRepository(),
+		internal.JobsConfig{Workers: 0, QueueSize: 10, ResultDir: t.TempDir(), Retention: time.Hour}, zap.NewExample())
+	require.Nil(t, jobService.Start(ctx))
+	defer jobService.Close(ctx)
+
+	input := domain.FizzBuzzInput{Int1: 3, Int2: 5, Limit: 15, Str1: "fizz", Str2: "buzz"}
+	queued, err := jobService.Submit(ctx, input)
+	require.Nil(t, err)
+
+	cancelled := make(chan domain.FizzbuzzJob)
+	go func() {
+		job, _ := jobService.Cancel(ctx, queued.ID)
+		cancelled <- job
+	}()
+	<-jobRepository.entered
+
+	// A slow repository call of Cancel does not hold up the other calls
+	submitted := make(chan errors.Error)
+	go func() {
+		_, err := jobService.Submit(ctx, input)
+		submitted <- err
+	}()
+	select {
+	case err := <-submitted:
+		assert.Nil(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("Submit is blocked by Cancel")
+	}
+
+	close(jobRepository.release)
+	assert.Equal(t, domain.JobStatusCancelled, (<-cancelled).Status)
+}
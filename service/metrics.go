@@ -0,0 +1,31 @@
+package service
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Generation modes, labelling the generated terms
+const (
+	generationModeSequence = "sequence"
+	generationModeBatch    = "batch"
+	generationModeStream   = "stream"
+)
+
+var (
+	generatedTerms = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "fizzbuzz_generated_terms_total",
+		Help: "Number of FizzBuzz terms generated, by generation mode.",
+	}, []string{"mode"})
+	requestedLimits = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "fizzbuzz_requested_limit",
+		Help:    "Distribution of the limit of the generated sequences.",
+		Buckets: prometheus.ExponentialBuckets(10, 10, 9),
+	})
+)
+
+// observeGeneration records a generation of terms out of a sequence of the given limit
+func observeGeneration(mode string, limit, terms int) {
+	generatedTerms.WithLabelValues(mode).Add(float64(terms))
+	requestedLimits.Observe(float64(limit))
+}
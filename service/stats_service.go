@@ -0,0 +1,112 @@
+package service
+
+import (
+	"context"
+	"lbc/fizzbuzz/domain"
+	"lbc/fizzbuzz/repository"
+	"sync"
+	"time"
+
+	"github.com/mwm-io/gapi/errors"
+)
+
+// trendingCacheTTL is how long a trending ranking is served from memory,
+// so that dashboards refreshing every few seconds do not each scan the history
+const trendingCacheTTL = 5 * time.Second
+
+// StatsService reads the stats of the recorded requests
+type StatsService interface {
+	GetMostHits(ctx context.Context) (domain.FizzbuzzRequest, errors.Error)
+	ListMostHits(ctx context.Context, query domain.StatsQuery) (domain.FizzbuzzRequestPage, errors.Error)
+	GetHitsSeries(ctx context.Context, query domain.StatsQuery) ([]domain.HitsBucket, errors.Error)
+	GetBreakdown(ctx context.Context, query domain.BreakdownQuery) (domain.BreakdownPage, errors.Error)
+	GetLimitHistogram(ctx context.Context, query domain.LimitHistogramQuery) ([]domain.LimitBucket, errors.Error)
+	GetTrending(ctx context.Context, window time.Duration, top int) ([]domain.TrendingRequest, errors.Error)
+}
+
+type statsService struct {
+	fizzBuzzRepository repository.FizzBuzzRepository
+
+	mu       sync.Mutex
+	trending map[domain.TrendingQuery]cachedTrending
+}
+
+// cachedTrending is a trending ranking and its expiration time
+type cachedTrending struct {
+	requests  []domain.TrendingRequest
+	expiresAt time.Time
+}
+
+func NewStatsService(fizzBuzzRepository repository.FizzBuzzRepository) StatsService {
+	return &statsService{
+		fizzBuzzRepository: fizzBuzzRepository,
+		trending:           make(map[domain.TrendingQuery]cachedTrending),
+	}
+}
+
+// GetMostHits returns the single most requested parameters, a not found error when nothing has been recorded
+func (s *statsService) GetMostHits(ctx context.Context) (domain.FizzbuzzRequest, errors.Error) {
+	request, err := s.fizzBuzzRepository.GetMostHits(ctx)
+	return request, readError(ctx, err)
+}
+
+// ListMostHits returns a page of the requests, sorted and filtered by query
+func (s *statsService) ListMostHits(ctx context.Context, query domain.StatsQuery) (domain.FizzbuzzRequestPage, errors.Error) {
+	page, err := s.fizzBuzzRepository.ListMostHits(ctx, query)
+	return page, readError(ctx, err)
+}
+
+// GetHitsSeries returns the hits of the window of query, per bucket
+func (s *statsService) GetHitsSeries(ctx context.Context, query domain.StatsQuery) ([]domain.HitsBucket, errors.Error) {
+	series, err := s.fizzBuzzRepository.GetHitsSeries(ctx, query)
+	return series, readError(ctx, err)
+}
+
+// GetBreakdown returns a page of the hits grouped by the dimensions of query
+func (s *statsService) GetBreakdown(ctx context.Context, query domain.BreakdownQuery) (domain.BreakdownPage, errors.Error) {
+	page, err := s.fizzBuzzRepository.GetBreakdown(ctx, query)
+	return page, readError(ctx, err)
+}
+
+// GetLimitHistogram returns the hits grouped by ranges of limits
+func (s *statsService) GetLimitHistogram(ctx context.Context, query domain.LimitHistogramQuery) ([]domain.LimitBucket, errors.Error) {
+	buckets, err := s.fizzBuzzRepository.GetLimitHistogram(ctx, query)
+	return buckets, readError(ctx, err)
+}
+
+// GetTrending ranks the requests by the growth of their hits within the last window compared to the previous one.
+// Windows end with the current domain.HitsResolution bucket, which is included.
+func (s *statsService) GetTrending(ctx context.Context, window time.Duration, top int) ([]domain.TrendingRequest, errors.Error) {
+	now := time.Now()
+	query := domain.TrendingQuery{
+		Window: window,
+		Top:    top,
+		Until:  now.Truncate(domain.HitsResolution).Add(domain.HitsResolution),
+	}
+	if err := query.Validate(); err != nil {
+		return nil, errors.Wrap(err).WithKind("invalid_input")
+	}
+
+	s.mu.Lock()
+	cached, ok := s.trending[query]
+	s.mu.Unlock()
+	if ok && now.Before(cached.expiresAt) {
+		return cached.requests, nil
+	}
+
+	requests, err := s.fizzBuzzRepository.GetTrending(ctx, query)
+	if err != nil {
+		return nil, readError(ctx, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, entry := range s.trending {
+		if !now.Before(entry.expiresAt) {
+			delete(s.trending, key)
+		}
+	}
+	s.trending[query] = cachedTrending{requests: requests, expiresAt: now.Add(trendingCacheTTL)}
+
+	return requests, nil
+}
@@ -0,0 +1,126 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"lbc/fizzbuzz/domain"
+	"lbc/fizzbuzz/internal"
+	"lbc/fizzbuzz/repository"
+	"lbc/fizzbuzz/service"
+	"lbc/fizzbuzz/testdata/utils"
+	"net/http"
+	"testing"
+	"time"
+
+	gapierrors "github.com/mwm-io/gapi/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestGetTrending(t *testing.T) {
+	db := internal.Clients.PostgreSQL()
+	fizzBuzzRepository := repository.NewFizzBuzzRepository(db, zap.NewExample())
+	statsService := service.NewStatsService(fizzBuzzRepository)
+	require.Nil(t, utils.ResetDatabase(db))
+
+	fizzBuzz := domain.FizzBuzzInput{Int1: 3, Int2: 5, Limit: 100, Str1: "fizz", Str2: "buzz"}
+	require.Nil(t, fizzBuzzRepository.Save(context.Background(), fizzBuzz))
+
+	requests, err := statsService.GetTrending(context.Background(), time.Hour, 10)
+	require.Nil(t, err)
+	require.Len(t, requests, 1)
+	assert.Equal(t, 1, requests[0].Hits)
+	assert.Equal(t, 0, requests[0].PreviousHits)
+	assert.Equal(t, 1.0, requests[0].Growth)
+
+	// The ranking is served from the cache for a few seconds
+	require.Nil(t, fizzBuzzRepository.Save(context.Background(), fizzBuzz))
+	requests, err = statsService.GetTrending(context.Background(), time.Hour, 10)
+	require.Nil(t, err)
+	require.Len(t, requests, 1)
+	assert.Equal(t, 1, requests[0].Hits)
+
+	// Other queries are not
+	requests, err = statsService.GetTrending(context.Background(), 2*time.Hour, 10)
+	require.Nil(t, err)
+	require.Len(t, requests, 1)
+	assert.Equal(t, 2, requests[0].Hits)
+
+	_, err = statsService.GetTrending(context.Background(), 30*time.Second, 10)
+	require.NotNil(t, err)
+	assert.Equal(t, "invalid_input", err.Kind())
+
+	_, err = statsService.GetTrending(context.Background(), time.Hour, 0)
+	require.NotNil(t, err)
+	assert.Equal(t, "invalid_input", err.Kind())
+}
+
+func TestStatsServiceCancellation(t *testing.T) {
+	// A database whose driver fails the queries of a done context, as the in-memory repository never does
+	db := utils.NewSQLiteDatabase(t)
+	statsService := service.NewStatsService(repository.NewFizzBuzzRepository(db, zap.NewExample()))
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	expired, cancelExpired := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancelExpired()
+
+	until := time.Now().Truncate(time.Minute)
+	statsQuery := domain.StatsQuery{Top: 10, Sort: domain.StatsSorts[0], Since: until.Add(-time.Hour), Until: until, Bucket: time.Minute}
+	calls := map[string]func(ctx context.Context) error{
+		"Most Hits": func(ctx context.Context) error {
+			_, err := statsService.GetMostHits(ctx)
+			return err
+		},
+		"List": func(ctx context.Context) error {
+			_, err := statsService.ListMostHits(ctx, statsQuery)
+			return err
+		},
+		"Series": func(ctx context.Context) error {
+			_, err := statsService.GetHitsSeries(ctx, statsQuery)
+			return err
+		},
+		"Breakdown": func(ctx context.Context) error {
+			_, err := statsService.GetBreakdown(ctx, domain.BreakdownQuery{Dimensions: []string{"int1"}, Top: 10})
+			return err
+		},
+		"Limits": func(ctx context.Context) error {
+			_, err := statsService.GetLimitHistogram(ctx, domain.LimitHistogramQuery{})
+			return err
+		},
+		"Trending": func(ctx context.Context) error {
+			_, err := statsService.GetTrending(ctx, time.Hour, 10)
+			return err
+		},
+	}
+
+	tests := []struct {
+		name   string
+		ctx    context.Context
+		kind   string
+		status int
+	}{
+		{name: "Cancelled", ctx: cancelled, kind: "request_cancelled", status: service.StatusClientClosedRequest},
+		{name: "Deadline Exceeded", ctx: expired, kind: "request_timeout", status: http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		for name, call := range calls {
+			t.Run(tt.name+" "+name, func(t *testing.T) {
+				err := call(tt.ctx)
+				require.Error(t, err)
+
+				var gErr gapierrors.Error
+				require.True(t, errors.As(err, &gErr))
+				assert.Equal(t, tt.kind, gErr.Kind())
+				assert.Equal(t, tt.status, gErr.StatusCode())
+			})
+		}
+	}
+
+	// Without a done context, the errors of the repository are returned as is
+	_, gErr := statsService.GetMostHits(context.Background())
+	require.NotNil(t, gErr)
+	assert.Equal(t, http.StatusNotFound, gErr.StatusCode())
+}
@@ -0,0 +1,8 @@
+package service
+
+import (
+	"go.opentelemetry.io/otel"
+)
+
+// tracer starts the spans of the services, exported by the tracer provider configured at startup
+var tracer = otel.Tracer("lbc/fizzbuzz/service")
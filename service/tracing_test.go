@@ -0,0 +1,51 @@
+package service_test
+
+import (
+	"context"
+	"lbc/fizzbuzz/domain"
+	"lbc/fizzbuzz/repository"
+	"lbc/fizzbuzz/service"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// TestFizzBuzzServiceSpans /
+func TestFizzBuzzServiceSpans(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	defer otel.SetTracerProvider(previous)
+
+	svc := service.NewFizzBuzzService(repository.NewMemoryFizzBuzzRepository())
+
+	// The spans of the service are children of the span of the caller, such as the HTTP handler
+	ctx, parent := provider.Tracer("test").Start(context.Background(), "handler")
+	_, err := svc.GenerateFizzBuzz(ctx, domain.FizzBuzzInput{Int1: 3, Int2: 5, Limit: 15, Str1: "fizz", Str2: "buzz"})
+	require.Nil(t, err)
+	_, err = svc.GenerateFizzBuzz(ctx, domain.FizzBuzzInput{Int1: 3, Int2: 5, Limit: 15, Str1: "", Str2: "buzz"})
+	require.NotNil(t, err)
+	parent.End()
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 3)
+
+	ok, failed := spans[0], spans[1]
+	assert.Equal(t, "FizzBuzzService.GenerateFizzBuzz", ok.Name())
+	assert.Equal(t, parent.SpanContext().SpanID(), ok.Parent().SpanID())
+	assert.Contains(t, ok.Attributes(), attribute.Int("fizzbuzz.limit", 15))
+	assert.Contains(t, ok.Attributes(), attribute.Int("fizzbuzz.terms", 15))
+	assert.Equal(t, codes.Unset, ok.Status().Code)
+
+	assert.Equal(t, codes.Error, failed.Status().Code)
+	assert.Equal(t, "invalid_input", failed.Status().Description)
+	require.Len(t, failed.Events(), 1)
+	assert.Equal(t, "exception", failed.Events()[0].Name)
+}
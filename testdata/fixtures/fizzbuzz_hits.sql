@@ -1,6 +1,15 @@
-TRUNCATE TABLE fizzbuzz_requests RESTART IDENTITY;
+TRUNCATE TABLE fizzbuzz_requests, fizzbuzz_request_hits RESTART IDENTITY;
 
-INSERT INTO fizzbuzz_requests (int1, int2, max_limit, str1, str2, hits) VALUES
-(3, 5, 100, 'fizz', 'buzz', 42),
-(2, 4, 50, 'foo', 'bar', 30),
-(5, 8, 50, 'john',  'doe', 10);
+INSERT INTO fizzbuzz_requests (int1, int2, max_limit, str1, str2, hits, first_seen, last_seen) VALUES
+(3, 5, 100, 'fizz', 'buzz', 42, '2024-01-01 10:00:00+00', '2024-01-01 10:01:00+00'),
+(3, 7, 200, 'fizz', 'bazz', 30, '2024-01-01 09:00:00+00', '2024-01-01 09:00:00+00'),
+(2, 4, 50, 'foo', 'bar', 30, '2024-01-01 11:00:00+00', '2024-01-01 11:00:00+00'),
+(5, 8, 50, 'john',  'doe', 10, '2024-01-01 10:00:00+00', '2024-01-01 10:00:00+00');
+
+-- 1704103200 is 2024-01-01T10:00:00Z
+INSERT INTO fizzbuzz_request_hits (int1, int2, max_limit, str1, str2, bucket, hits) VALUES
+(3, 5, 100, 'fizz', 'buzz', 1704103200, 30),
+(3, 5, 100, 'fizz', 'buzz', 1704103260, 12),
+(3, 7, 200, 'fizz', 'bazz', 1704099600, 30),
+(2, 4, 50, 'foo', 'bar', 1704106800, 30),
+(5, 8, 50, 'john',  'doe', 1704103200, 10);
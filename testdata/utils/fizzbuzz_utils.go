@@ -2,11 +2,17 @@ package utils
 
 import (
 	"context"
+	"database/sql"
+	"lbc/fizzbuzz/migrations"
 	"os"
 	"path/filepath"
+	"testing"
 
 	"github.com/mwm-io/gapi/errors"
 	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+	"github.com/uptrace/bun/dialect/sqlitedialect"
+	"github.com/uptrace/bun/driver/sqliteshim"
 )
 
 func LoadFixtures(db *bun.DB) errors.Error {
@@ -27,11 +33,41 @@ func LoadFixtures(db *bun.DB) errors.Error {
 	return nil
 }
 
+// ResetDatabase applies the pending migrations then empties the tables
 func ResetDatabase(db *bun.DB) errors.Error {
-	_, err := db.Exec("TRUNCATE TABLE fizzbuzz_requests RESTART IDENTITY")
+	if _, err := migrations.Up(context.Background(), db); err != nil {
+		return err
+	}
+
+	query := "TRUNCATE TABLE fizzbuzz_requests, fizzbuzz_request_hits, fizzbuzz_jobs RESTART IDENTITY"
+	if db.Dialect().Name() == dialect.SQLite {
+		// SQLite has no TRUNCATE
+		query = "DELETE FROM fizzbuzz_requests; DELETE FROM fizzbuzz_request_hits; DELETE FROM fizzbuzz_jobs"
+	}
+
+	_, err := db.Exec(query)
 	if err != nil {
 		return errors.Wrap(err).WithKind("truncate_error")
 	}
 
 	return nil
 }
+
+// NewSQLiteDatabase returns a migrated SQLite database in a temporary directory of t, closed at the end of the test,
+// so that tests neither share state nor leave a database behind
+func NewSQLiteDatabase(t *testing.T) *bun.DB {
+	t.Helper()
+
+	sqldb, err := sql.Open(sqliteshim.ShimName, "file:"+filepath.Join(t.TempDir(), "fizzbuzz.db"))
+	if err != nil {
+		t.Fatalf("failed to open the SQLite database: %s", err)
+	}
+	db := bun.NewDB(sqldb, sqlitedialect.New())
+	t.Cleanup(func() { _ = db.Close() })
+
+	if _, err := migrations.Up(context.Background(), db); err != nil {
+		t.Fatalf("failed to migrate the SQLite database: %s", err)
+	}
+
+	return db
+}